@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// 缓存相关的错误
+var (
+	ErrMiss     = errors.New("cache: miss")      // 缓存中没有该key
+	ErrNotFound = errors.New("cache: not found") // 数据不存在，Loader返回该错误时会被短暂缓存，避免缓存穿透
+)
+
+// Loader 缓存未命中时加载数据，数据不存在时返回ErrNotFound
+type Loader func(ctx context.Context) (interface{}, error)
+
+// Conf 缓存相关配置
+type Conf struct {
+	KeyPrefix   string  `yaml:"keyPrefix"`   // redis中的key前缀，默认cache
+	Jitter      float64 `yaml:"jitter"`      // 过期时间随机增加的比例，避免大量key同时过期，默认0.1，为负数时不增加
+	NotFoundTTL int     `yaml:"notFoundTTL"` // 数据不存在时的缓存时长，单位：秒，默认60
+	StaleTTL    int     `yaml:"staleTTL"`    // 大于0时开启stale-while-revalidate：过期后该时长内仍返回旧值并在后台刷新，单位：秒
+}
+
+// 缓存值的格式：1字节标记 + 8字节逻辑过期毫秒(未开启stale-while-revalidate时为0) + 序列化后的值
+const (
+	flagValue    byte = 'v'
+	flagNotFound byte = 'n'
+	headerLen         = 9
+)
+
+// Cache 基于redis的缓存
+type Cache struct {
+	conf  Conf
+	rdb   *redis.Client
+	codec Codec
+	group group
+	log   *zap.SugaredLogger
+}
+
+// NewCache 创建缓存，codec为nil时使用JSON
+func NewCache(conf Conf, rdb *redis.Client, codec Codec) *Cache {
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "cache"
+	}
+	if conf.Jitter == 0 {
+		conf.Jitter = 0.1
+	}
+	if conf.NotFoundTTL <= 0 {
+		conf.NotFoundTTL = 60
+	}
+	if codec == nil {
+		codec = JSON{}
+	}
+	return &Cache{conf: conf, rdb: rdb, codec: codec, log: zap.NewNop().Sugar()}
+}
+
+// SetLogger 设置日志，用于记录redis读写失败及后台刷新失败，默认不输出
+func (c *Cache) SetLogger(log *zap.SugaredLogger) {
+	c.log = log
+}
+
+// Get 读取缓存到dest，不存在时返回ErrMiss，缓存了数据不存在时返回ErrNotFound
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, _, err := c.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, dest)
+}
+
+// Set 写入缓存，实际过期时间会加上随机抖动
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.set(ctx, key, flagValue, data, ttl)
+}
+
+// Delete 删除缓存
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	wrapped := make([]string, len(keys))
+	for i, key := range keys {
+		wrapped[i] = c.key(key)
+	}
+	return c.rdb.Del(ctx, wrapped...).Err()
+}
+
+// GetOrLoad 读取缓存到dest，未命中时调用loader加载并写入缓存，同一实例内对同一个key的并发未命中只加载一次
+// 数据不存在时返回ErrNotFound；开启stale-while-revalidate时，过期的值直接返回并在后台刷新；redis不可用时直接调用loader
+func (c *Cache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, loader Loader) error {
+	data, _, err := c.getOrLoad(ctx, key, ttl, loader)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(data, dest)
+}
+
+// getOrLoad 返回序列化后的值，hit表示是否命中redis
+func (c *Cache) getOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader) (data []byte, hit bool, err error) {
+	data, stale, err := c.get(ctx, key)
+	switch {
+	case err == nil:
+		if stale && !c.group.running(key) {
+			go c.refresh(key, ttl, loader)
+		}
+		return data, true, nil
+	case errors.Is(err, ErrNotFound):
+		return nil, true, err
+	case err != ErrMiss:
+		// redis不可用时不写缓存，但同样合并并发的加载，避免所有请求同时打到数据源
+		c.log.Warnw("cache: read redis failed, fallback to loader", "key", key, "err", err)
+		data, err, _ = c.group.do(key, func() ([]byte, error) {
+			v, err := loader(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return c.codec.Marshal(v)
+		})
+		return data, false, err
+	}
+
+	data, err, _ = c.group.do(key, func() ([]byte, error) {
+		return c.load(ctx, key, ttl, loader)
+	})
+	return data, false, err
+}
+
+// refresh 后台刷新过期的值
+func (c *Cache) refresh(key string, ttl time.Duration, loader Loader) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err, _ := c.group.do(key, func() ([]byte, error) {
+		return c.load(ctx, key, ttl, loader)
+	})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		c.log.Warnw("cache: refresh failed", "key", key, "err", err)
+	}
+}
+
+// load 调用loader并写入缓存，数据不存在时写入不存在标记
+func (c *Cache) load(ctx context.Context, key string, ttl time.Duration, loader Loader) ([]byte, error) {
+	v, err := loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if err := c.set(ctx, key, flagNotFound, nil, time.Duration(c.conf.NotFoundTTL)*time.Second); err != nil {
+			c.log.Warnw("cache: write redis failed", "key", key, "err", err)
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.set(ctx, key, flagValue, data, ttl); err != nil {
+		c.log.Warnw("cache: write redis failed", "key", key, "err", err)
+	}
+	return data, nil
+}
+
+// get 读取缓存，stale表示已超过逻辑过期时间
+func (c *Cache) get(ctx context.Context, key string) (data []byte, stale bool, err error) {
+	raw, err := c.rdb.Get(ctx, c.key(key)).Bytes()
+	if err == goredis.Nil {
+		return nil, false, ErrMiss
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(raw) < headerLen {
+		return nil, false, ErrMiss
+	}
+	if raw[0] == flagNotFound {
+		return nil, false, ErrNotFound
+	}
+	expireAt := int64(binary.BigEndian.Uint64(raw[1:headerLen]))
+	stale = expireAt > 0 && time.Now().UnixNano()/1e6 >= expireAt
+	return raw[headerLen:], stale, nil
+}
+
+// set 写入缓存；开启stale-while-revalidate时redis中的过期时间为ttl+StaleTTL，值中记录逻辑过期时间
+func (c *Cache) set(ctx context.Context, key string, flag byte, data []byte, ttl time.Duration) error {
+	ttl = c.jitter(ttl)
+	raw := make([]byte, headerLen+len(data))
+	raw[0] = flag
+	copy(raw[headerLen:], data)
+	if c.conf.StaleTTL > 0 && flag == flagValue {
+		binary.BigEndian.PutUint64(raw[1:headerLen], uint64(time.Now().Add(ttl).UnixNano()/1e6))
+		ttl += time.Duration(c.conf.StaleTTL) * time.Second
+	}
+	return c.rdb.Set(ctx, c.key(key), raw, ttl).Err()
+}
+
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if c.conf.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(int64(float64(ttl)*c.conf.Jitter)+1))
+}
+
+func (c *Cache) key(key string) string {
+	return c.rdb.WrapKey(c.conf.KeyPrefix + ":" + key)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 缓存值的序列化方式
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSON 使用encoding/json序列化，可读性好，便于排查
+type JSON struct{}
+
+func (JSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// Msgpack 使用msgpack序列化，体积和速度都优于JSON
+type Msgpack struct{}
+
+func (Msgpack) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (Msgpack) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// Gzip 在另一种序列化方式的结果上做gzip压缩，适合体积较大的值
+type Gzip struct {
+	Codec Codec // 压缩前的序列化方式，为nil时使用JSON
+}
+
+func (g Gzip) Marshal(v interface{}) ([]byte, error) {
+	data, err := g.codec().Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g Gzip) Unmarshal(data []byte, v interface{}) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return g.codec().Unmarshal(raw, v)
+}
+
+func (g Gzip) codec() Codec {
+	if g.Codec == nil {
+		return JSON{}
+	}
+	return g.Codec
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry 本地缓存项，notFound表示缓存的是数据不存在
+type entry struct {
+	key      string
+	data     []byte
+	notFound bool
+	expireAt time.Time
+}
+
+// lru 带过期时间的LRU，超过容量时淘汰最久未使用的项
+type lru struct {
+	mu        sync.Mutex
+	size      int
+	items     map[string]*list.Element
+	order     *list.List // 队首为最近使用
+	evictions int64
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, items: map[string]*list.Element{}, order: list.New()}
+}
+
+func (l *lru) get(key string) (*entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expireAt) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e, true
+}
+
+func (l *lru) set(e *entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[e.key]; ok {
+		el.Value = e
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[e.key] = l.order.PushFront(e)
+	for l.order.Len() > l.size {
+		l.removeElement(l.order.Back())
+		l.evictions++
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+// stats 当前缓存的数量及累计淘汰的数量
+func (l *lru) stats() (length int, evictions int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len(), l.evictions
+}
+
+func (l *lru) removeElement(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*entry).key)
+}
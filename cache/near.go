@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// NearConf 本地缓存相关配置
+type NearConf struct {
+	Size    int    `yaml:"size"`    // 本地最多缓存的key数量，默认10000
+	TTL     int    `yaml:"ttl"`     // 本地缓存时长，单位：秒，默认5，pub/sub消息丢失时本地数据最多旧这么久
+	Channel string `yaml:"channel"` // 失效通知的频道，默认cache:invalidate，会加上redis的命名空间
+}
+
+// NearStats 命中统计
+type NearStats struct {
+	LocalHits     int64 `json:"localHits"`     // 命中本地缓存
+	RemoteHits    int64 `json:"remoteHits"`    // 本地未命中，命中redis
+	Misses        int64 `json:"misses"`        // 都未命中，调用了loader
+	Evictions     int64 `json:"evictions"`     // 超过容量被淘汰的数量
+	Invalidations int64 `json:"invalidations"` // 收到其他实例的失效通知而删除的数量
+	Size          int   `json:"size"`          // 当前本地缓存的数量
+}
+
+// invalidation 失效通知
+type invalidation struct {
+	Source string   `json:"src"`  // 发送通知的实例，忽略自己发出的通知
+	Keys   []string `json:"keys"` // 失效的key
+}
+
+// NearCache 两级缓存：进程内LRU + redis，热点key不必每次都访问redis
+// 通过Set/Delete修改数据时，经redis pub/sub通知所有实例删除本地缓存
+type NearCache struct {
+	conf   NearConf
+	remote *Cache
+	local  *lru
+	id     string
+	pubsub *goredis.PubSub
+
+	localHits     int64
+	remoteHits    int64
+	misses        int64
+	invalidations int64
+}
+
+// NewNearCache 在remote之前加一层本地缓存，并订阅失效通知
+func NewNearCache(conf NearConf, remote *Cache) (*NearCache, error) {
+	if conf.Size <= 0 {
+		conf.Size = 10000
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = 5
+	}
+	if conf.Channel == "" {
+		conf.Channel = "cache:invalidate"
+	}
+	if ns := remote.rdb.Namespace(); ns != "" {
+		conf.Channel = ns + ":" + conf.Channel
+	}
+	id, err := newInstanceId()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &NearCache{conf: conf, remote: remote, local: newLRU(conf.Size), id: id}
+	c.pubsub = remote.rdb.Subscribe(context.Background(), conf.Channel)
+	if _, err := c.pubsub.Receive(context.Background()); err != nil {
+		_ = c.pubsub.Close()
+		return nil, err
+	}
+	go c.listen()
+	return c, nil
+}
+
+// Close 取消订阅
+func (c *NearCache) Close() error {
+	return c.pubsub.Close()
+}
+
+// Get 读取缓存到dest，本地未命中时读取redis，不存在时返回ErrMiss，缓存了数据不存在时返回ErrNotFound
+func (c *NearCache) Get(ctx context.Context, key string, dest interface{}) error {
+	if e, ok := c.local.get(key); ok {
+		atomic.AddInt64(&c.localHits, 1)
+		return c.decode(e, dest)
+	}
+	data, _, err := c.remote.get(ctx, key)
+	if err == ErrMiss {
+		atomic.AddInt64(&c.misses, 1)
+		return err
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	atomic.AddInt64(&c.remoteHits, 1)
+	e := c.store(key, data, errors.Is(err, ErrNotFound))
+	return c.decode(e, dest)
+}
+
+// GetOrLoad 依次读取本地缓存、redis，都未命中时调用loader，加载结果同时写入redis和本地缓存
+func (c *NearCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, dest interface{}, loader Loader) error {
+	if e, ok := c.local.get(key); ok {
+		atomic.AddInt64(&c.localHits, 1)
+		return c.decode(e, dest)
+	}
+	data, hit, err := c.remote.getOrLoad(ctx, key, ttl, loader)
+	if hit {
+		atomic.AddInt64(&c.remoteHits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	e := c.store(key, data, errors.Is(err, ErrNotFound))
+	return c.decode(e, dest)
+}
+
+// Set 写入redis和本地缓存，并通知其他实例删除本地缓存
+func (c *NearCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.remote.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err = c.remote.set(ctx, key, flagValue, data, ttl); err != nil {
+		return err
+	}
+	c.store(key, data, false)
+	return c.publish(ctx, key)
+}
+
+// Delete 删除redis和所有实例的本地缓存
+func (c *NearCache) Delete(ctx context.Context, keys ...string) error {
+	if err := c.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.local.remove(key)
+	}
+	return c.publish(ctx, keys...)
+}
+
+// Stats 命中统计
+func (c *NearCache) Stats() NearStats {
+	size, evictions := c.local.stats()
+	return NearStats{
+		LocalHits:     atomic.LoadInt64(&c.localHits),
+		RemoteHits:    atomic.LoadInt64(&c.remoteHits),
+		Misses:        atomic.LoadInt64(&c.misses),
+		Evictions:     evictions,
+		Invalidations: atomic.LoadInt64(&c.invalidations),
+		Size:          size,
+	}
+}
+
+func (c *NearCache) store(key string, data []byte, notFound bool) *entry {
+	e := &entry{key: key, data: data, notFound: notFound, expireAt: time.Now().Add(time.Duration(c.conf.TTL) * time.Second)}
+	c.local.set(e)
+	return e
+}
+
+func (c *NearCache) decode(e *entry, dest interface{}) error {
+	if e.notFound {
+		return ErrNotFound
+	}
+	return c.remote.codec.Unmarshal(e.data, dest)
+}
+
+func (c *NearCache) publish(ctx context.Context, keys ...string) error {
+	msg, err := json.Marshal(invalidation{Source: c.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return c.remote.rdb.Publish(ctx, c.conf.Channel, msg).Err()
+}
+
+// listen 处理其他实例的失效通知，订阅断开重连期间的通知会丢失，由本地缓存的短TTL兜底
+func (c *NearCache) listen() {
+	for msg := range c.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			c.remote.log.Warnw("cache: bad invalidation message", "payload", msg.Payload, "err", err)
+			continue
+		}
+		if inv.Source == c.id {
+			continue
+		}
+		for _, key := range inv.Keys {
+			c.local.remove(key)
+		}
+		atomic.AddInt64(&c.invalidations, int64(len(inv.Keys)))
+	}
+}
+
+func newInstanceId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "sync"
+
+// call 一次正在进行中的加载
+type call struct {
+	wg   sync.WaitGroup
+	data []byte
+	err  error
+}
+
+// group 合并对同一个key的并发加载，同一时刻只有一个调用真正执行，其他调用等待并共享结果
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do 执行fn，shared表示结果是否来自其他调用
+func (g *group) do(key string, fn func() ([]byte, error)) (data []byte, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*call{}
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.data, c.err, true
+	}
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.data, c.err = fn()
+	return c.data, c.err, false
+}
+
+// running 是否有对key的加载正在进行
+func (g *group) running(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
@@ -0,0 +1,84 @@
+// idtool 雪花ID调试工具，自定义了位分配时通过参数指定
+//
+//	idtool decode 676652790499255552 ...
+//	idtool range "2026-10-01 00:00:00" "2026-10-02 00:00:00"
+//	idtool -worker-bits 10 -seq-bits 12 -epoch 1631082836000 decode 676652790499255552
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/idworker"
+	"github.com/18689221165/lynn-toolkit/types"
+)
+
+func main() {
+	conf := idworker.DefaultConf()
+	dcBits := flag.Uint("dc-bits", uint(conf.DataCenterBits), "数据中心ID位数")
+	workerBits := flag.Uint("worker-bits", uint(conf.WorkerBits), "机器ID位数")
+	seqBits := flag.Uint("seq-bits", uint(conf.SequenceBits), "序列号位数")
+	flag.Int64Var(&conf.Epoch, "epoch", conf.Epoch, "初始时间戳(毫秒)")
+	flag.Usage = usage
+	flag.Parse()
+	conf.DataCenterBits, conf.WorkerBits, conf.SequenceBits = uint8(*dcBits), uint8(*workerBits), uint8(*seqBits)
+	if err := conf.Validate(); err != nil {
+		fail("%v", err)
+	}
+
+	args := flag.Args()
+	if len(args) < 2 {
+		usage()
+	}
+	switch args[0] {
+	case "decode":
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				fail("invalid id %s: %v", arg, err)
+			}
+			info, err := conf.Decompose(id)
+			if err != nil {
+				fail("%v", err)
+			}
+			out, _ := json.Marshal(info)
+			fmt.Println(string(out))
+		}
+	case "range":
+		start := parseTime(args[1])
+		end := start
+		if len(args) > 2 {
+			end = parseTime(args[2])
+		}
+		min, max, err := conf.IdRange(start, end)
+		if err != nil {
+			fail("%v", err)
+		}
+		fmt.Printf("minId=%d\nmaxId=%d\n", min, max)
+	default:
+		usage()
+	}
+}
+
+func parseTime(value string) time.Time {
+	t, err := types.ParseTimeStr(types.TimeFormart, value)
+	if err != nil {
+		fail("invalid time %s, layout is %s", value, types.TimeFormart)
+	}
+	return time.Time(t)
+}
+
+func usage() {
+	_, _ = fmt.Fprintf(os.Stderr, "usage:\n  idtool [flags] decode <id>...\n  idtool [flags] range <start> [end]    time layout: %s\nflags:\n", types.TimeFormart)
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func fail(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,32 @@
+package gojwt
+
+import "context"
+
+type claimsCtxKey struct{}
+
+// NewContext 将claims放入context.Context中，便于service层获取当前登录用户
+func NewContext(ctx context.Context, claims *RoleClaims) context.Context {
+	return context.WithValue(ctx, claimsCtxKey{}, claims)
+}
+
+// FromContext 从context.Context中取出claims，不存在时返回false
+func FromContext(ctx context.Context) (*RoleClaims, bool) {
+	claims, ok := ctx.Value(claimsCtxKey{}).(*RoleClaims)
+	return claims, ok && claims != nil
+}
+
+// UIDFromContext 从context.Context中取出当前用户ID
+func UIDFromContext(ctx context.Context) string {
+	if claims, ok := FromContext(ctx); ok {
+		return claims.Subject
+	}
+	return ""
+}
+
+// RoleFromContext 从context.Context中取出当前用户角色
+func RoleFromContext(ctx context.Context) string {
+	if claims, ok := FromContext(ctx); ok {
+		return claims.Role
+	}
+	return ""
+}
@@ -2,7 +2,9 @@ package gojwt
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/dgrijalva/jwt-go"
+	"strings"
 	"time"
 )
 
@@ -11,8 +13,21 @@ type Conf struct {
 	Header string `yaml:"header"` // 传token时http head的名称
 	TTL    uint   `yaml:"ttl"`    // 有效时间，单位：秒
 	Issuer string `yaml:"issuer"` // 颁发者，一般指系统名
+
+	RenewWindow uint   `yaml:"renewWindow"` // 续签窗口，单位：秒，token剩余有效期小于该值时自动续签，0表示不续签
+	MaxLifetime uint   `yaml:"maxLifetime"` // 会话最长存活时间，单位：秒，从首次签发(IssuedAt)算起，0表示不限制
+	RenewHeader string `yaml:"renewHeader"` // 续签后新token写入的响应头，默认X-Renewed-Token
+	RenewCookie string `yaml:"renewCookie"` // 续签后新token写入的cookie名，为空则不写cookie
+
+	Audience     string   `yaml:"audience"`     // 受众，签发时写入token，校验时要求一致，为空不校验
+	VerifyIssuer bool     `yaml:"verifyIssuer"` // 校验时是否要求颁发者与Issuer一致
+	Leeway       uint     `yaml:"leeway"`       // 校验时间时允许的时钟偏差，单位：秒
+	Algorithms   []string `yaml:"algorithms"`   // 允许的签名算法，为空时只允许HS256
 }
 
+// ErrMaxLifetime 会话已达到最长存活时间，不能再续签
+var ErrMaxLifetime = errors.New("jwt: session reached max lifetime")
+
 // JwtToken jwt的token
 type JwtToken struct {
 	UID           string `json:"uid"`           // 所有者ID
@@ -28,50 +43,98 @@ func (t JwtToken) JSON() string {
 
 // RoleClaims 带角色的claims
 type RoleClaims struct {
-	Role string
+	Role   string
+	Scope  string `json:"scope,omitempty"`  // 授权范围，多个以空格分隔，OAuth2客户端token使用
+	Device string `json:"device,omitempty"` // 设备ID，会话管理使用
 	jwt.StandardClaims
 }
 
+// HasScope 判断claims是否包含指定的授权范围
+func (c RoleClaims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateToken 创建jwt token
 func CreateToken(uid, role string, cfg Conf) (*JwtToken, error) {
-	now := time.Now()
-	expireTime := now.Add(time.Duration(cfg.TTL) * time.Second)
+	return CreateScopedToken(uid, role, nil, cfg)
+}
 
+// CreateScopedToken 创建带授权范围的jwt token
+func CreateScopedToken(uid, role string, scopes []string, cfg Conf) (*JwtToken, error) {
+	now := time.Now()
 	claims := RoleClaims{
-		Role: role,
+		Role:  role,
+		Scope: strings.Join(scopes, " "),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expireTime.Unix(),
+			ExpiresAt: expireTime(now, now, cfg).Unix(),
 			Issuer:    cfg.Issuer,
+			Audience:  cfg.Audience,
 			IssuedAt:  now.Unix(),
 			Subject:   uid,
 		},
 	}
+	return signToken(claims, now, cfg)
+}
+
+// RenewToken 续签token，保留原始的签发时间IssuedAt，新的过期时间不会超过MaxLifetime
+func RenewToken(claims *RoleClaims, cfg Conf) (*JwtToken, error) {
+	now := time.Now()
+	expire := expireTime(now, time.Unix(claims.IssuedAt, 0), cfg)
+	if expire.Unix() <= claims.ExpiresAt {
+		return nil, ErrMaxLifetime
+	}
+
+	renewed := *claims
+	renewed.ExpiresAt = expire.Unix()
+	return signToken(renewed, now, cfg)
+}
+
+// NeedRenew 判断token是否进入了续签窗口
+func NeedRenew(claims *RoleClaims, cfg Conf) bool {
+	if cfg.RenewWindow == 0 {
+		return false
+	}
+	remain := claims.ExpiresAt - time.Now().Unix()
+	return remain <= int64(cfg.RenewWindow)
+}
+
+// expireTime 计算过期时间：now+TTL，且不超过issuedAt+MaxLifetime
+func expireTime(now, issuedAt time.Time, cfg Conf) time.Time {
+	expire := now.Add(time.Duration(cfg.TTL) * time.Second)
+	if cfg.MaxLifetime > 0 {
+		if deadline := issuedAt.Add(time.Duration(cfg.MaxLifetime) * time.Second); expire.After(deadline) {
+			expire = deadline
+		}
+	}
+	return expire
+}
+
+// signToken 使用HS256签名claims
+func signToken(claims RoleClaims, now time.Time, cfg Conf) (*JwtToken, error) {
 	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	token, err := tokenClaims.SignedString([]byte(cfg.Secret))
 	if err != nil {
 		return nil, err
 	}
 	return &JwtToken{
-		UID:           uid,
-		Role:          role,
+		UID:           claims.Subject,
+		Role:          claims.Role,
 		Token:         token,
-		EffectiveTime: cfg.TTL,
+		EffectiveTime: uint(claims.ExpiresAt - now.Unix()),
 	}, nil
 }
 
-// ParseToken 解析token
+// ParseToken 解析token，只校验签名(HS256)和有效期
 func ParseToken(token, secret string) (*RoleClaims, error) {
-	//用于解析鉴权的声明，方法内部主要是具体的解码和校验的过程，最终返回*Token
-	tokenClaims, err := jwt.ParseWithClaims(token, &RoleClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
-	if tokenClaims != nil {
-		// 从tokenClaims中获取到Claims对象，并使用断言，将该对象转换为我们自己定义的Claims
-		// 要传入指针，项目中结构体都是用指针传递，节省空间。
-		if claims, ok := tokenClaims.Claims.(*RoleClaims); ok && tokenClaims.Valid {
-			return claims, nil
-		}
-	}
-	return nil, err
+	return ParseTokenWithPolicy(token, secret, Policy{})
+}
+
+// IsExpired 判断ParseToken返回的错误是否为token已过期
+func IsExpired(err error) bool {
+	return errors.Is(err, ErrTokenExpired)
 }
@@ -0,0 +1,126 @@
+package gojwt
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/18689221165/lynn-toolkit/database"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// OAuth2 client_credentials授权的错误
+var (
+	ErrInvalidClient = errors.New("oauth: invalid client")
+	ErrInvalidScope  = errors.New("oauth: invalid scope")
+)
+
+// ClientConf 配置文件中注册的OAuth2客户端
+type ClientConf struct {
+	ClientID   string   `yaml:"clientId"`   // 客户端ID
+	SecretHash string   `yaml:"secretHash"` // bcrypt加密后的客户端密钥，可用HashSecret生成
+	Scopes     []string `yaml:"scopes"`     // 允许申请的授权范围
+	Role       string   `yaml:"role"`       // 签发token时使用的角色
+}
+
+// OAuthClient 数据库中注册的OAuth2客户端
+type OAuthClient struct {
+	database.Model
+	ClientID   string `gorm:"size:64;uniqueIndex:uk_client_id"` // 客户端ID
+	SecretHash string `gorm:"size:128"`                         // bcrypt加密后的客户端密钥
+	Scopes     string `gorm:"size:512"`                         // 允许申请的授权范围，多个以空格分隔
+	Role       string `gorm:"size:64"`                          // 签发token时使用的角色
+	Disabled   bool   // 是否禁用
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_client"
+}
+
+// ClientStore OAuth2客户端存储，客户端不存在时返回ErrInvalidClient
+type ClientStore interface {
+	Client(ctx context.Context, clientID string) (*ClientConf, error)
+}
+
+// StaticClientStore 从配置文件加载的客户端
+type StaticClientStore map[string]ClientConf
+
+func NewStaticClientStore(clients []ClientConf) StaticClientStore {
+	store := make(StaticClientStore, len(clients))
+	for _, c := range clients {
+		store[c.ClientID] = c
+	}
+	return store
+}
+
+func (s StaticClientStore) Client(_ context.Context, clientID string) (*ClientConf, error) {
+	if c, ok := s[clientID]; ok {
+		return &c, nil
+	}
+	return nil, ErrInvalidClient
+}
+
+// GormClientStore 通过gorm从数据库读取客户端
+type GormClientStore struct {
+	db *gorm.DB
+}
+
+func NewGormClientStore(db *gorm.DB) *GormClientStore {
+	return &GormClientStore{db: db}
+}
+
+func (s *GormClientStore) Client(ctx context.Context, clientID string) (*ClientConf, error) {
+	var c OAuthClient
+	err := s.db.WithContext(ctx).Where("client_id = ? AND disabled = ?", clientID, false).Take(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ClientConf{ClientID: c.ClientID, SecretHash: c.SecretHash, Scopes: strings.Fields(c.Scopes), Role: c.Role}, nil
+}
+
+// HashSecret 使用bcrypt加密客户端密钥，用于注册客户端
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// AuthenticateClient 校验客户端ID和密钥
+func AuthenticateClient(ctx context.Context, store ClientStore, clientID, secret string) (*ClientConf, error) {
+	client, err := store.Client(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// IssueClientToken 按client_credentials模式签发token
+// scope为空格分隔的申请范围，为空时授予客户端允许的全部范围
+func IssueClientToken(ctx context.Context, store ClientStore, clientID, secret, scope string, cfg Conf) (*JwtToken, []string, error) {
+	client, err := AuthenticateClient(ctx, store, clientID, secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes := client.Scopes
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		for _, s := range requested {
+			if !contains(client.Scopes, s) {
+				return nil, nil, ErrInvalidScope
+			}
+		}
+		scopes = requested
+	}
+
+	token, err := CreateScopedToken(client.ClientID, client.Role, scopes, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, scopes, nil
+}
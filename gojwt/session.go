@@ -0,0 +1,253 @@
+package gojwt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	"github.com/18689221165/lynn-toolkit/types"
+	"github.com/dgrijalva/jwt-go"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// 会话相关的错误
+var (
+	ErrTooManySessions = errors.New("jwt: too many active sessions")
+	ErrSessionRevoked  = errors.New("jwt: session has been revoked")
+)
+
+// SessionPolicy 超过最大设备数时的处理策略
+type SessionPolicy string
+
+const (
+	// SessionEvict 踢掉最早的会话
+	SessionEvict SessionPolicy = "evict"
+	// SessionRefuse 拒绝新的登录
+	SessionRefuse SessionPolicy = "refuse"
+)
+
+// SessionConf 会话管理相关配置
+type SessionConf struct {
+	MaxDevices int           `yaml:"maxDevices"` // 每个账号最多同时在线的设备数，0表示不限制
+	Policy     SessionPolicy `yaml:"policy"`     // 超过上限时的处理策略：evict(默认)、refuse
+	KeyPrefix  string        `yaml:"keyPrefix"`  // 会话在redis中的key前缀，默认session
+}
+
+// Session 登录会话
+type Session struct {
+	ID        string     `json:"id"`        // 会话ID，即token的jti
+	UID       string     `json:"uid"`       // 用户ID
+	Role      string     `json:"role"`      // 角色
+	Device    string     `json:"device"`    // 设备ID
+	LoginAt   types.Time `json:"loginAt"`   // 登录时间
+	ExpiresAt types.Time `json:"expiresAt"` // 过期时间
+}
+
+// register 注册会话：清理过期会话，同一设备的旧会话被替换，超过上限时按策略踢出或拒绝
+// 返回值第一个元素为1表示成功、0表示被拒绝，其后为被踢掉的会话ID
+var register = goredis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[4])
+local alive = redis.call('HKEYS', KEYS[2])
+for _, id in ipairs(alive) do
+	if not redis.call('ZSCORE', KEYS[1], id) then redis.call('HDEL', KEYS[2], id) end
+end
+local result = {1}
+if ARGV[8] ~= '' then
+	local all = redis.call('HGETALL', KEYS[2])
+	for i = 1, #all, 2 do
+		if cjson.decode(all[i + 1]).device == ARGV[8] then
+			redis.call('ZREM', KEYS[1], all[i])
+			redis.call('HDEL', KEYS[2], all[i])
+			table.insert(result, all[i])
+		end
+	end
+end
+local limit = tonumber(ARGV[5])
+if limit > 0 then
+	local over = redis.call('ZCARD', KEYS[1]) - limit + 1
+	if over > 0 then
+		if ARGV[6] == '1' then return {0} end
+		local oldest = redis.call('ZRANGE', KEYS[1], 0, over - 1)
+		for _, id in ipairs(oldest) do
+			redis.call('ZREM', KEYS[1], id)
+			redis.call('HDEL', KEYS[2], id)
+			table.insert(result, id)
+		end
+	end
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[7])
+redis.call('EXPIRE', KEYS[2], ARGV[7])
+return result
+`)
+
+// SessionRegistry 基于redis的会话管理，记录每个用户在各设备上签发的token(jti)
+type SessionRegistry struct {
+	conf    SessionConf
+	jwtConf Conf
+	rdb     *redis.Client
+}
+
+func NewSessionRegistry(conf SessionConf, jwtConf Conf, rdb *redis.Client) *SessionRegistry {
+	if conf.Policy == "" {
+		conf.Policy = SessionEvict
+	}
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "session"
+	}
+	return &SessionRegistry{conf: conf, jwtConf: jwtConf, rdb: rdb}
+}
+
+// Login 登录并签发token，同时注册会话；返回被踢下线的会话ID
+func (r *SessionRegistry) Login(ctx context.Context, uid, role, device string) (*JwtToken, []string, error) {
+	jti, err := newSessionID()
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	expire := expireTime(now, now, r.jwtConf)
+	claims := RoleClaims{
+		Role:   role,
+		Device: device,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			ExpiresAt: expire.Unix(),
+			Issuer:    r.jwtConf.Issuer,
+			Audience:  r.jwtConf.Audience,
+			IssuedAt:  now.Unix(),
+			Subject:   uid,
+		},
+	}
+
+	session := Session{ID: jti, UID: uid, Role: role, Device: device, LoginAt: types.Time(now)}
+	detail, _ := json.Marshal(session)
+	refuse := "0"
+	if r.conf.Policy == SessionRefuse {
+		refuse = "1"
+	}
+	res, err := register.Run(ctx, r.rdb, r.keys(uid),
+		jti, detail, toMillis(expire), toMillis(now), r.conf.MaxDevices, refuse, int64(r.ttl()/time.Second), device).Slice()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(res) == 0 || res[0].(int64) == 0 {
+		return nil, nil, ErrTooManySessions
+	}
+	evicted := make([]string, 0, len(res)-1)
+	for _, id := range res[1:] {
+		evicted = append(evicted, id.(string))
+	}
+
+	token, err := signToken(claims, now, r.jwtConf)
+	if err != nil {
+		_ = r.Revoke(ctx, uid, jti)
+		return nil, nil, err
+	}
+	return token, evicted, nil
+}
+
+// Validate 校验token对应的会话是否仍然有效，未通过Login签发(没有jti)的token不做校验
+func (r *SessionRegistry) Validate(ctx context.Context, claims *RoleClaims) error {
+	if claims.Id == "" {
+		return nil
+	}
+	score, err := r.rdb.ZScore(ctx, r.keys(claims.Subject)[0], claims.Id).Result()
+	if err == goredis.Nil || (err == nil && int64(score) < toMillis(time.Now())) {
+		return ErrSessionRevoked
+	}
+	return err
+}
+
+// Touch token续签后更新会话的过期时间
+func (r *SessionRegistry) Touch(ctx context.Context, uid, sessionID string, expiresAt time.Time) error {
+	keys := r.keys(uid)
+	_, err := r.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.ZAddXX(ctx, keys[0], &goredis.Z{Score: float64(toMillis(expiresAt)), Member: sessionID})
+		pipe.Expire(ctx, keys[0], r.ttl())
+		pipe.Expire(ctx, keys[1], r.ttl())
+		return nil
+	})
+	return err
+}
+
+// List 列出用户当前有效的会话
+func (r *SessionRegistry) List(ctx context.Context, uid string) ([]Session, error) {
+	keys := r.keys(uid)
+	now := toMillis(time.Now())
+	scores, err := r.rdb.ZRangeByScoreWithScores(ctx, keys[0], &goredis.ZRangeBy{Min: "(" + strconv.FormatInt(now, 10), Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+	details, err := r.rdb.HGetAll(ctx, keys[1]).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(scores))
+	for _, z := range scores {
+		id := z.Member.(string)
+		var s Session
+		if err = json.Unmarshal([]byte(details[id]), &s); err != nil {
+			s = Session{ID: id, UID: uid}
+		}
+		s.ExpiresAt = types.Time(time.Unix(0, int64(z.Score)*1e6))
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Revoke 注销用户的指定会话
+func (r *SessionRegistry) Revoke(ctx context.Context, uid string, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	keys := r.keys(uid)
+	members := make([]interface{}, len(sessionIDs))
+	for i, id := range sessionIDs {
+		members[i] = id
+	}
+	_, err := r.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.ZRem(ctx, keys[0], members...)
+		pipe.HDel(ctx, keys[1], sessionIDs...)
+		return nil
+	})
+	return err
+}
+
+// RevokeAll 注销用户的全部会话
+func (r *SessionRegistry) RevokeAll(ctx context.Context, uid string) error {
+	return r.rdb.Del(ctx, r.keys(uid)...).Err()
+}
+
+// keys 会话有序集合(score为过期时间的毫秒数，避免同一秒内登录的会话无法区分先后)和会话详情hash，使用{uid}保证集群模式下落在同一个slot
+func (r *SessionRegistry) keys(uid string) []string {
+	base := r.rdb.WrapKey(r.conf.KeyPrefix + ":{" + uid + "}")
+	return []string{base, base + ":detail"}
+}
+
+// ttl 会话key的存活时间，取会话最长存活时间与token有效期中较大的
+func (r *SessionRegistry) ttl() time.Duration {
+	ttl := r.jwtConf.TTL
+	if r.jwtConf.MaxLifetime > ttl {
+		ttl = r.jwtConf.MaxLifetime
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / 1e6
+}
@@ -0,0 +1,144 @@
+package gojwt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// token校验失败的错误类型，可以用errors.Is判断
+var (
+	ErrTokenMalformed   = errors.New("jwt: token is malformed")
+	ErrTokenExpired     = errors.New("jwt: token is expired")
+	ErrTokenNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrSignatureInvalid = errors.New("jwt: signature is invalid")
+	ErrAlgorithmInvalid = errors.New("jwt: signing algorithm is not allowed")
+	ErrAudienceInvalid  = errors.New("jwt: audience is invalid")
+	ErrIssuerInvalid    = errors.New("jwt: issuer is invalid")
+)
+
+// Policy token校验策略
+type Policy struct {
+	Issuer     string        // 期望的颁发者，为空不校验
+	Audience   []string      // 允许的受众，为空不校验
+	Leeway     time.Duration // 校验exp、nbf、iat时允许的时钟偏差
+	Algorithms []string      // 允许的签名算法，仅支持HS256、HS384、HS512，为空时只允许HS256
+}
+
+// Policy 根据配置生成校验策略
+func (cfg Conf) Policy() Policy {
+	p := Policy{
+		Leeway:     time.Duration(cfg.Leeway) * time.Second,
+		Algorithms: cfg.Algorithms,
+	}
+	if cfg.VerifyIssuer {
+		p.Issuer = cfg.Issuer
+	}
+	if cfg.Audience != "" {
+		p.Audience = []string{cfg.Audience}
+	}
+	return p
+}
+
+// ParseTokenWithPolicy 按校验策略解析token，校验失败时返回本包定义的错误类型
+func ParseTokenWithPolicy(token, secret string, p Policy) (*RoleClaims, error) {
+	algorithms := p.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{jwt.SigningMethodHS256.Alg()}
+	}
+
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	tokenClaims, err := parser.ParseWithClaims(token, &RoleClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok || !contains(algorithms, t.Method.Alg()) {
+			return nil, ErrAlgorithmInvalid
+		}
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return nil, convertError(err)
+	}
+
+	claims, ok := tokenClaims.Claims.(*RoleClaims)
+	if !ok || !tokenClaims.Valid {
+		return nil, ErrTokenMalformed
+	}
+	if err = p.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// validate 校验claims中的时间、颁发者和受众
+func (p Policy) validate(claims *RoleClaims) error {
+	now := time.Now().Unix()
+	leeway := int64(p.Leeway / time.Second)
+
+	if claims.ExpiresAt != 0 && now > claims.ExpiresAt+leeway {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now+leeway < claims.NotBefore {
+		return ErrTokenNotValidYet
+	}
+	if claims.IssuedAt != 0 && now+leeway < claims.IssuedAt {
+		return ErrTokenNotValidYet
+	}
+	if p.Issuer != "" && claims.Issuer != p.Issuer {
+		return ErrIssuerInvalid
+	}
+	if len(p.Audience) > 0 && !contains(p.Audience, claims.Audience) {
+		return ErrAudienceInvalid
+	}
+	return nil
+}
+
+// convertError 将jwt-go的错误转换成本包的错误类型
+func convertError(err error) error {
+	var ve *jwt.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	if ve.Inner == ErrAlgorithmInvalid {
+		return ErrAlgorithmInvalid
+	}
+	switch {
+	case ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return ErrTokenMalformed
+	case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return ErrSignatureInvalid
+	case ve.Errors&jwt.ValidationErrorExpired != 0:
+		return ErrTokenExpired
+	case ve.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return ErrTokenNotValidYet
+	}
+	return ErrTokenMalformed
+}
+
+// ErrorResult 将token校验错误映射为service中的错误码
+func ErrorResult(err error) *service.ApiResult {
+	switch {
+	case errors.Is(err, ErrTokenExpired), errors.Is(err, ErrMaxLifetime):
+		return service.ErrTokenExpired
+	case errors.Is(err, ErrTokenNotValidYet):
+		return service.ErrTokenNotValidYet
+	case errors.Is(err, ErrSignatureInvalid), errors.Is(err, ErrAlgorithmInvalid):
+		return service.ErrTokenSignature
+	case errors.Is(err, ErrAudienceInvalid), errors.Is(err, ErrIssuerInvalid):
+		return service.ErrTokenAudience
+	case errors.Is(err, ErrSessionRevoked):
+		return service.ErrSessionRevoked
+	case errors.Is(err, ErrTooManySessions):
+		return service.ErrTooManySessions
+	}
+	return service.ErrTokenInvalid
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package idworker
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCode 无法解码的公开ID
+var ErrInvalidCode = errors.New("idworker: invalid id code")
+
+const (
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // Crockford base32，去掉了I、L、O、U
+	feistelRounds     = 4
+)
+
+// IdCodec 对外公开ID的编码方式，ID与字符串之间可逆转换
+type IdCodec interface {
+	Encode(id uint64) string
+	Decode(code string) (uint64, error)
+}
+
+// Base62 使用0-9A-Za-z编码，长度最短，区分大小写
+type Base62 struct{}
+
+func (Base62) Encode(id uint64) string {
+	return encodeBase(id, base62Alphabet)
+}
+
+func (Base62) Decode(code string) (uint64, error) {
+	return decodeBase(code, func(c byte) int { return strings.IndexByte(base62Alphabet, c) }, 62)
+}
+
+// Base32 使用Crockford base32编码，不区分大小写，解码时I、L视为1，O视为0，忽略连字符
+// 按Crockford规范这些写法都能解码到同一个ID，需要唯一表示时以Encode的结果为准
+type Base32 struct{}
+
+func (Base32) Encode(id uint64) string {
+	return encodeBase(id, crockfordAlphabet)
+}
+
+func (Base32) Decode(code string) (uint64, error) {
+	code = strings.ReplaceAll(strings.ToUpper(code), "-", "")
+	return decodeBase(code, func(c byte) int {
+		switch c {
+		case 'I', 'L':
+			c = '1'
+		case 'O':
+			c = '0'
+		}
+		return strings.IndexByte(crockfordAlphabet, c)
+	}, 32)
+}
+
+func encodeBase(id uint64, alphabet string) string {
+	if id == 0 {
+		return alphabet[:1]
+	}
+	base := uint64(len(alphabet))
+	buf := make([]byte, 0, 13)
+	for id > 0 {
+		buf = append(buf, alphabet[id%base])
+		id /= base
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// decodeBase 解码，拒绝有前导0的编码，保证每个ID只对应一个编码
+func decodeBase(code string, index func(c byte) int, base uint64) (uint64, error) {
+	if code == "" || len(code) > 1 && index(code[0]) == 0 {
+		return 0, ErrInvalidCode
+	}
+	var id uint64
+	for i := 0; i < len(code); i++ {
+		n := index(code[i])
+		if n < 0 {
+			return 0, ErrInvalidCode
+		}
+		// 溢出检查
+		if id > (^uint64(0)-uint64(n))/base {
+			return 0, ErrInvalidCode
+		}
+		id = id*base + uint64(n)
+	}
+	return id, nil
+}
+
+// Obfuscator 使用密钥对ID做可逆的置换(Feistel网络)后再编码，
+// 对外隐藏ID的连续性及其中的时间、机器信息，不同密钥得到的结果不同
+type Obfuscator struct {
+	keys  [feistelRounds]uint32
+	codec IdCodec
+}
+
+// NewObfuscator 创建ID混淆器，codec为置换后的编码方式，为nil时使用Base62
+func NewObfuscator(secret string, codec IdCodec) *Obfuscator {
+	if codec == nil {
+		codec = Base62{}
+	}
+	sum := sha256.Sum256([]byte(secret))
+	o := &Obfuscator{codec: codec}
+	for i := range o.keys {
+		o.keys[i] = binary.BigEndian.Uint32(sum[i*4:])
+	}
+	return o
+}
+
+// Permute 置换ID，结果与原ID一一对应
+func (o *Obfuscator) Permute(id uint64) uint64 {
+	l, r := uint32(id>>32), uint32(id)
+	for _, k := range o.keys {
+		l, r = r, l^feistel(r, k)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+// Restore 还原被置换的ID
+func (o *Obfuscator) Restore(id uint64) uint64 {
+	l, r := uint32(id>>32), uint32(id)
+	for i := len(o.keys) - 1; i >= 0; i-- {
+		l, r = r^feistel(l, o.keys[i]), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (o *Obfuscator) Encode(id uint64) string {
+	return o.codec.Encode(o.Permute(id))
+}
+
+func (o *Obfuscator) Decode(code string) (uint64, error) {
+	id, err := o.codec.Decode(code)
+	if err != nil {
+		return 0, err
+	}
+	return o.Restore(id), nil
+}
+
+// feistel 轮函数，对输入做混淆扩散
+func feistel(x, key uint32) uint32 {
+	x ^= key
+	x *= 0x9E3779B1
+	x ^= x >> 16
+	x *= 0x85EBCA6B
+	x ^= x >> 13
+	return x
+}
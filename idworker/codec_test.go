@@ -0,0 +1,96 @@
+package idworker
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// codecSamples 边界值加上随机值
+func codecSamples() []uint64 {
+	ids := []uint64{0, 1, 31, 32, 61, 62, 63, 1 << 32, math.MaxInt64, math.MaxUint64 - 1, math.MaxUint64}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, r.Uint64(), uint64(r.Int63n(1<<40)))
+	}
+	return ids
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codecs := map[string]IdCodec{
+		"base62":       Base62{},
+		"base32":       Base32{},
+		"obfuscator":   NewObfuscator("secret", nil),
+		"obfuscator32": NewObfuscator("secret", Base32{}),
+	}
+	for name, codec := range codecs {
+		for _, id := range codecSamples() {
+			code := codec.Encode(id)
+			got, err := codec.Decode(code)
+			if err != nil || got != id {
+				t.Fatalf("%s: Decode(Encode(%d)=%q) = %d, %v", name, id, code, got, err)
+			}
+		}
+	}
+}
+
+func TestBase62(t *testing.T) {
+	cases := map[uint64]string{0: "0", 61: "z", 62: "10", math.MaxUint64: "LygHa16AHYF"}
+	for id, want := range cases {
+		if got := (Base62{}).Encode(id); got != want {
+			t.Errorf("Encode(%d) = %q, want %q", id, got, want)
+		}
+	}
+	for _, code := range []string{"", "00", "01", "a-b", "LygHa16AHYG", "zzzzzzzzzzzz"} {
+		if id, err := (Base62{}).Decode(code); err == nil {
+			t.Errorf("Decode(%q) = %d, want error", code, id)
+		}
+	}
+}
+
+func TestBase32(t *testing.T) {
+	var b Base32
+	if got := b.Encode(math.MaxUint64); got != "FZZZZZZZZZZZZ" {
+		t.Errorf("Encode(max) = %q", got)
+	}
+	// Crockford：不区分大小写，I、L为1，O为0，忽略连字符
+	aliases := map[string]uint64{"1": 1, "i": 1, "L": 1, "l": 1, "10": 32, "1o": 32, "1O": 32, "z": 31, "a-b": 10*32 + 11, "FZZZ-ZZZZ-ZZZZZ": math.MaxUint64}
+	for code, want := range aliases {
+		if got, err := b.Decode(code); err != nil || got != want {
+			t.Errorf("Decode(%q) = %d, %v, want %d", code, got, err, want)
+		}
+	}
+	for _, code := range []string{"", "-", "00", "O1", "U", "*", "G0000000000000", "10000000000000"} {
+		if id, err := b.Decode(code); err == nil {
+			t.Errorf("Decode(%q) = %d, want error", code, id)
+		}
+	}
+}
+
+func TestObfuscatorPermute(t *testing.T) {
+	o := NewObfuscator("secret", nil)
+	other := NewObfuscator("other", nil)
+	same := 0
+	for _, id := range codecSamples() {
+		p := o.Permute(id)
+		if got := o.Restore(p); got != id {
+			t.Fatalf("Restore(Permute(%d)) = %d", id, got)
+		}
+		if other.Permute(id) == p {
+			same++
+		}
+	}
+	if same > 1 {
+		t.Errorf("%d ids permute the same under different secrets", same)
+	}
+
+	// 连续的ID置换后不再连续
+	prev := o.Permute(1000)
+	for id := uint64(1001); id < 1100; id++ {
+		p := o.Permute(id)
+		if p == prev+1 {
+			t.Fatalf("Permute(%d) follows Permute(%d)", id, id-1)
+		}
+		prev = p
+	}
+}
@@ -0,0 +1,108 @@
+package idworker
+
+import (
+	"fmt"
+	"time"
+)
+
+// 时间戳至少保留的位数，39位大约可以使用17年
+const minTimeBits = 39
+
+// 按当前时间计算，时间戳部分至少还能使用的时长，避免配置的初始时间戳过早导致ID很快溢出
+const minEpochHeadroom = 5 * 365 * 24 * time.Hour
+
+// Conf 雪花算法的位分配、初始时间戳及节点ID
+// 各位数都为0时使用默认布局：数据中心0位、机器14位、序列号8位
+type Conf struct {
+	DataCenterBits uint8 `yaml:"dataCenterBits"` // 数据中心ID位数
+	WorkerBits     uint8 `yaml:"workerBits"`     // 机器ID位数
+	SequenceBits   uint8 `yaml:"sequenceBits"`   // 毫秒内序列号位数
+	Epoch          int64 `yaml:"epoch"`          // 初始时间戳，单位：毫秒，为0时使用默认值，一旦使用不允许修改
+	DataCenterId   int64 `yaml:"dataCenterId"`   // 数据中心ID
+	WorkerId       int64 `yaml:"workerId"`       // 机器ID
+}
+
+// DefaultConf 默认的雪花算法配置
+func DefaultConf() Conf {
+	return Conf{DataCenterBits: dataCenterBits, WorkerBits: workerBits, SequenceBits: sequenceBits, Epoch: epoch}
+}
+
+// layout 根据配置计算出的各部分最大值和偏移量
+type layout struct {
+	dataCenterMax int64
+	workerMax     int64
+	sequenceMax   int64
+
+	workerShift     uint8
+	dataCenterShift uint8
+	timeShift       uint8
+	epoch           int64
+	maxMillis       int64 // 时间戳部分的最大值，超过后ID溢出为负数
+}
+
+// withDefaults 填充未配置的位数和初始时间戳
+func (c Conf) withDefaults() Conf {
+	if c.DataCenterBits == 0 && c.WorkerBits == 0 && c.SequenceBits == 0 {
+		c.DataCenterBits, c.WorkerBits, c.SequenceBits = dataCenterBits, workerBits, sequenceBits
+	}
+	if c.Epoch == 0 {
+		c.Epoch = epoch
+	}
+	return c
+}
+
+// layout 校验配置并计算布局
+func (c Conf) layout() (layout, error) {
+	c = c.withDefaults()
+	if c.SequenceBits == 0 {
+		return layout{}, fmt.Errorf("idworker: sequenceBits must be greater than 0")
+	}
+	if total := int(c.DataCenterBits) + int(c.WorkerBits) + int(c.SequenceBits); total > 63-minTimeBits {
+		return layout{}, fmt.Errorf("idworker: dataCenterBits+workerBits+sequenceBits=%d exceeds %d", total, 63-minTimeBits)
+	}
+	now := time.Now().UnixNano() / 1e6
+	if c.Epoch < 0 || c.Epoch > now {
+		return layout{}, fmt.Errorf("idworker: epoch %d is invalid", c.Epoch)
+	}
+	timeShift := c.SequenceBits + c.WorkerBits + c.DataCenterBits
+	maxMillis := int64(-1 ^ (-1 << (63 - timeShift)))
+	if now-c.Epoch > maxMillis-minEpochHeadroom.Milliseconds() {
+		return layout{}, fmt.Errorf("idworker: epoch %d is too early, %d time bits last until %s", c.Epoch, 63-timeShift,
+			time.Unix(0, (c.Epoch+maxMillis)*1e6).Format("2006-01-02"))
+	}
+
+	l := layout{
+		dataCenterMax:   -1 ^ (-1 << c.DataCenterBits),
+		workerMax:       -1 ^ (-1 << c.WorkerBits),
+		sequenceMax:     -1 ^ (-1 << c.SequenceBits),
+		workerShift:     c.SequenceBits,
+		dataCenterShift: c.SequenceBits + c.WorkerBits,
+		timeShift:       timeShift,
+		epoch:           c.Epoch,
+		maxMillis:       maxMillis,
+	}
+	if c.DataCenterId < 0 || c.DataCenterId > l.dataCenterMax {
+		return layout{}, fmt.Errorf("idworker: dataCenterId exceeded maximum value %v", l.dataCenterMax)
+	}
+	if c.WorkerId < 0 || c.WorkerId > l.workerMax {
+		return layout{}, fmt.Errorf("idworker: workerId exceeded maximum value %v", l.workerMax)
+	}
+	return l, nil
+}
+
+// Validate 校验位分配、初始时间戳及节点ID是否合法
+func (c Conf) Validate() error {
+	_, err := c.layout()
+	return err
+}
+
+// MaxWorkerId 按配置的位数可用的最大机器ID
+func (c Conf) MaxWorkerId() int64 {
+	c = c.withDefaults()
+	return -1 ^ (-1 << c.WorkerBits)
+}
+
+// compose 组装ID
+func (l layout) compose(millis, dataCenterId, workerId, sequence int64) int64 {
+	return (millis-l.epoch)<<l.timeShift | dataCenterId<<l.dataCenterShift | workerId<<l.workerShift | sequence
+}
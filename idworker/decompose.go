@@ -0,0 +1,77 @@
+package idworker
+
+import (
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/types"
+)
+
+// IdInfo 雪花ID解析出来的各部分
+type IdInfo struct {
+	Id           int64      `json:"id,string"`    // 原始ID
+	Time         types.Time `json:"time"`         // 生成时间
+	DataCenterId int64      `json:"dataCenterId"` // 数据中心ID
+	WorkerId     int64      `json:"workerId"`     // 机器ID
+	Sequence     int64      `json:"sequence"`     // 毫秒内序列号
+}
+
+// Decompose 按默认的位分配解析雪花ID，得到生成时间、机器ID和序列号
+func Decompose(id int64) IdInfo {
+	return defaultLayout().decompose(id)
+}
+
+// IdRange 按默认的位分配计算时间段[start, end]内生成的ID范围，可用于按Model.ID做范围查询代替按创建时间查询
+func IdRange(start, end time.Time) (min, max int64) {
+	l := defaultLayout()
+	return l.minIdAt(start), l.maxIdAt(end)
+}
+
+// Decompose 按配置的位分配解析雪花ID，配置不合法时返回错误
+func (c Conf) Decompose(id int64) (IdInfo, error) {
+	l, err := c.layout()
+	if err != nil {
+		return IdInfo{}, err
+	}
+	return l.decompose(id), nil
+}
+
+// IdRange 按配置的位分配计算时间段[start, end]内生成的ID范围，配置不合法时返回错误
+func (c Conf) IdRange(start, end time.Time) (min, max int64, err error) {
+	l, err := c.layout()
+	if err != nil {
+		return 0, 0, err
+	}
+	return l.minIdAt(start), l.maxIdAt(end), nil
+}
+
+// Decompose 按当前worker的位分配解析雪花ID
+func (w *SnowflakeWorker) Decompose(id int64) IdInfo {
+	return w.layout.decompose(id)
+}
+
+// defaultLayout 默认配置的布局，默认配置总是合法的
+func defaultLayout() layout {
+	l, _ := DefaultConf().layout()
+	return l
+}
+
+func (l layout) decompose(id int64) IdInfo {
+	millis := id>>l.timeShift + l.epoch
+	return IdInfo{
+		Id:           id,
+		Time:         types.Time(time.Unix(0, millis*1e6)),
+		DataCenterId: id >> l.dataCenterShift & l.dataCenterMax,
+		WorkerId:     id >> l.workerShift & l.workerMax,
+		Sequence:     id & l.sequenceMax,
+	}
+}
+
+// minIdAt 指定时间(毫秒)可能生成的最小ID
+func (l layout) minIdAt(t time.Time) int64 {
+	return (t.UnixNano()/1e6 - l.epoch) << l.timeShift
+}
+
+// maxIdAt 指定时间(毫秒)可能生成的最大ID
+func (l layout) maxIdAt(t time.Time) int64 {
+	return l.minIdAt(t) | (1<<l.timeShift - 1)
+}
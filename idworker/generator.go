@@ -0,0 +1,99 @@
+package idworker
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidId 无法解析的ID
+var ErrInvalidId = errors.New("idworker: invalid id")
+
+// Generator 可替换的ID生成器，业务代码依赖该接口而不是具体实现，便于切换算法和在测试中替换
+// ID统一以字符串形式返回，雪花ID为十进制数字
+type Generator interface {
+	// Generate 生成下一个ID
+	Generate() (string, error)
+	// Timestamp 从该生成器生成的ID中解析出生成时间
+	Timestamp(id string) (time.Time, error)
+}
+
+var (
+	_ Generator = (*SnowflakeWorker)(nil)
+	_ Generator = (*UUIDv7)(nil)
+	_ Generator = (*ULID)(nil)
+	_ Generator = (*FakeGenerator)(nil)
+)
+
+// Generate 生成下一个雪花ID的十进制字符串
+func (w *SnowflakeWorker) Generate() (string, error) {
+	id, err := w.NextIdE()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Timestamp 按当前worker的位分配解析雪花ID的生成时间
+func (w *SnowflakeWorker) Timestamp(id string) (time.Time, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, ErrInvalidId
+	}
+	return time.Time(w.Decompose(n).Time), nil
+}
+
+// TimeOf 根据ID的格式解析生成时间：带连字符的36位为UUIDv7，26位为ULID，纯数字按默认位分配的雪花ID解析
+func TimeOf(id string) (time.Time, error) {
+	switch {
+	case len(id) == uuidLen && strings.Count(id, "-") == 4:
+		return uuidTime(id)
+	case len(id) == ulidLen:
+		return ulidTime(id)
+	default:
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || n < 0 {
+			return time.Time{}, ErrInvalidId
+		}
+		return time.Time(Decompose(n).Time), nil
+	}
+}
+
+// FakeGenerator 测试用的确定性ID生成器，生成雪花ID
+// 时钟从start开始，每生成一个ID前进step，相同的start和step总是得到相同的ID序列
+type FakeGenerator struct {
+	mu     sync.Mutex
+	now    time.Time
+	step   time.Duration
+	worker *SnowflakeWorker
+}
+
+// NewFakeGenerator 创建确定性ID生成器，step小于1毫秒时按1毫秒处理
+// start晚于默认初始时间戳(2021-09-08)时使用默认布局，生成的ID可以通过TimeOf、Decompose解析；
+// 否则使用以start为准的初始时间戳，生成的ID只能通过FakeGenerator.Timestamp解析
+func NewFakeGenerator(start time.Time, step time.Duration) *FakeGenerator {
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+	g := &FakeGenerator{now: start.Add(-step), step: step}
+	g.worker = NewSnowflakeWorker(0)
+	if millis := start.UnixNano() / 1e6; millis <= g.worker.layout.epoch {
+		// start不晚于默认初始时间戳时，初始时间戳改为start的前一毫秒，否则无法生成ID
+		g.worker.layout.epoch = millis - 1
+	}
+	g.worker.SetClock(func() time.Time { return g.now })
+	return g
+}
+
+func (g *FakeGenerator) Generate() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.now = g.now.Add(g.step)
+	return g.worker.Generate()
+}
+
+func (g *FakeGenerator) Timestamp(id string) (time.Time, error) {
+	return g.worker.Timestamp(id)
+}
@@ -1,44 +1,73 @@
 package idworker
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// 雪花算法格式：时间戳（42） + 数据中心（5）+ 机器（5）+ 序列号（12）
+// 雪花算法格式：时间戳（41） + 数据中心（0）+ 机器（14）+ 序列号（8），可通过Conf调整
 
 const (
 	dataCenterBits uint8 = 0  // 数据中心的ID位数，5位最大可以有2^5-1=31个节点
 	workerBits     uint8 = 14 // 机器的ID位数 5位最大可以有2^5-1=31个节点
 	sequenceBits   uint8 = 8  // 表示每个集群下的每个节点，1毫秒内可生成的id序号的二进制位数 即每毫秒可生成 2^12-1=4096个唯一ID
 
-	//dataCenterMax int64 = -1 ^ (-1 << dataCenterBits) // 数据中心Id的最大值，用于防止溢出
-	workerMax   int64 = -1 ^ (-1 << workerBits)   // 机器ID的最大值，用于防止溢出
-	sequenceMax int64 = -1 ^ (-1 << sequenceBits) // 序列号的最大值，用于防止溢出
-
-	workerShift     = sequenceBits                     // 机器ID向左的偏移量
-	dataCenterShift = workerBits + workerShift         // 数据中心ID向左的偏移量
-	timeShift       = dataCenterBits + dataCenterShift // 时间戳向左的偏移量
+	workerMax int64 = -1 ^ (-1 << workerBits) // 机器ID的最大值，用于防止溢出
 
 	// 41位字节作为时间戳数值的话 大约68年就会用完
 	// 雪花算法初始时间戳，一旦设置不允许修改
 	epoch int64 = 1631082836000
 )
 
+// 时钟回拨时，小于该值的回拨等待时钟追上，超过则直接返回错误
+const defaultMaxBackwardWait = 10 * time.Millisecond
+
+// ErrTimeOverflow 时间戳部分已用完，继续生成的ID会溢出为负数
+var ErrTimeOverflow = errors.New("idworker: timestamp bits exhausted")
+
+// ClockBackwardError 时钟回拨超过了允许等待的范围
+type ClockBackwardError struct {
+	Drift time.Duration // 回拨的时长
+}
+
+func (e *ClockBackwardError) Error() string {
+	return fmt.Sprintf("idworker: clock moved backwards by %s", e.Drift)
+}
+
+// 阻塞等待时钟回拨时每次最多休眠的时长，回拨较大时每隔这么久通知一次仍在等待
+const backwardWaitStep = time.Second
+
+// ClockBackwardState 时钟回拨的处理状态
+type ClockBackwardState int
+
+const (
+	ClockBackwardWaiting   ClockBackwardState = iota // 检测到回拨，开始等待时钟追上，阻塞等待较大的回拨时每秒通知一次剩余的回拨时长
+	ClockBackwardRecovered                           // 时钟已追上，drift为实际等待的时长
+	ClockBackwardFailed                              // 回拨超过允许等待的范围，err为*ClockBackwardError
+)
+
+// ClockBackwardHook 时钟回拨时的回调，可用于监控告警，err只在ClockBackwardFailed时不为nil
+type ClockBackwardHook func(state ClockBackwardState, drift time.Duration, err error)
+
 type WorkerId int64
 type DataCenterId int64
 
 // SnowflakeWorker 雪花算法
+// 上一次生成的时间戳和序列号打包在一个int64中，通过CAS无锁更新，确保并发安全
 type SnowflakeWorker struct {
-	mu           sync.Mutex // 添加互斥锁 确保并发安全
-	dataCenterId int64      // 数据中心Id
-	workerId     int64      // 机器ID
-	epoch        int64      // 雪花算法初始时间戳
-
-	timestamp int64 // 记录时间戳
-	sequence  int64 // 当前毫秒已经生成的id序列号(从0开始累加) 1毫秒内最多生成4096个ID
+	state        int64  // 上一次生成ID的状态：(时间戳-epoch)<<sequenceBits | 序列号，放在首位保证原子操作的内存对齐
+	dataCenterId int64  // 数据中心Id
+	workerId     int64  // 机器ID
+	layout       layout // 位分配及初始时间戳
+
+	clock           func() time.Time  // 时钟，测试时可注入
+	maxBackwardWait time.Duration     // 时钟回拨时最多等待的时长
+	onBackward      ClockBackwardHook // 时钟回拨回调
+	lease           *WorkerLease      // workerId的租约，丢失后停止生成ID
 }
 
 // NewSnowflakeWorkerForPid 使用当前进程号除最大的worker数量的余数作者workerId
@@ -47,53 +76,171 @@ func NewSnowflakeWorkerForPid() *SnowflakeWorker {
 	return NewSnowflakeWorker(WorkerId(wid))
 }
 
-// NewSnowflakeWorker 实例化一个雪花算法
+// NewSnowflakeWorker 使用默认的位分配实例化一个雪花算法
 func NewSnowflakeWorker(workId WorkerId) *SnowflakeWorker {
-	//dataCenterId := conf.DataCenterId
-	//if dataCenterId < 0 || dataCenterId > dataCenterMax {
-	//	log.Fatalf("dataCenterId exceeded maximum value %v", dataCenterMax)
-	//}
-
-	workerId := int64(workId)
-	if workerId < 0 || workerId > workerMax {
-		log.Fatalf("workerId exceeded maximum value %v", workerMax)
+	conf := DefaultConf()
+	conf.WorkerId = int64(workId)
+	w, err := NewSnowflakeWorkerWithConf(conf)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+	return w
+}
 
+// NewSnowflakeWorkerWithConf 按配置的位分配、初始时间戳及节点ID实例化雪花算法
+func NewSnowflakeWorkerWithConf(conf Conf) (*SnowflakeWorker, error) {
+	l, err := conf.layout()
+	if err != nil {
+		return nil, err
+	}
 	return &SnowflakeWorker{
-		epoch: epoch,
-		//dataCenterId: dataCenterId,
-		workerId:  workerId,
-		timestamp: 0,
-		sequence:  0,
+		layout:          l,
+		dataCenterId:    conf.DataCenterId,
+		workerId:        conf.WorkerId,
+		clock:           time.Now,
+		maxBackwardWait: defaultMaxBackwardWait,
+	}, nil
+}
+
+// NewSnowflakeWorkerWithLease 使用从redis租用的workerId实例化雪花算法，租约丢失后NextIdE返回ErrLeaseLost
+func NewSnowflakeWorkerWithLease(conf Conf, lease *WorkerLease) (*SnowflakeWorker, error) {
+	conf.WorkerId = int64(lease.WorkerId())
+	w, err := NewSnowflakeWorkerWithConf(conf)
+	if err != nil {
+		return nil, err
 	}
+	w.lease = lease
+	return w, nil
+}
+
+// SetClock 替换时钟，用于测试时模拟时钟回拨，需在生成ID之前设置
+func (w *SnowflakeWorker) SetClock(clock func() time.Time) {
+	w.clock = clock
+}
+
+// SetMaxBackwardWait 设置时钟回拨时最多等待的时长，为0时任何回拨都直接返回错误，需在生成ID之前设置
+func (w *SnowflakeWorker) SetMaxBackwardWait(d time.Duration) {
+	w.maxBackwardWait = d
 }
 
-// NextId 生成下一个Id
+// SetClockBackwardHook 设置时钟回拨回调，需在生成ID之前设置
+func (w *SnowflakeWorker) SetClockBackwardHook(hook ClockBackwardHook) {
+	w.onBackward = hook
+}
+
+// NextId 生成下一个Id，时钟回拨时不受maxBackwardWait限制，一直阻塞到时钟追上，等待期间回调ClockBackwardWaiting
+// 需要在回拨过大时快速失败请使用NextIdE；使用租约时租约丢失会panic，也应使用NextIdE
 func (w *SnowflakeWorker) NextId() int64 {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	// 获取生成时的时间戳
-	now := time.Now().UnixNano() / 1e6 // 纳秒转毫秒
-	if w.timestamp == now {
-		w.sequence++
-
-		// 判断当前节点是否在1毫秒内已经生成sequenceMax个ID
-		if w.sequence > sequenceMax {
-			// 如果当前工作节点在1毫秒内生成的ID已经超过上限 需要等待1毫秒再继续生成
-			for now <= w.timestamp {
-				now = time.Now().UnixNano() / 1e6
+	var id int64
+	err := w.reserve(1, true, func(millis, first, count int64) {
+		id = w.layout.compose(millis, w.dataCenterId, w.workerId, first)
+	})
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// NextIdE 生成下一个Id，时钟回拨超过允许等待的范围时返回*ClockBackwardError
+func (w *SnowflakeWorker) NextIdE() (int64, error) {
+	var id int64
+	err := w.reserve(1, false, func(millis, first, count int64) {
+		id = w.layout.compose(millis, w.dataCenterId, w.workerId, first)
+	})
+	return id, err
+}
+
+// NextIds 批量生成n个Id，每次CAS预留当前毫秒内尽可能多的序列号，比循环调用NextIdE更快；n为0时返回nil，小于0时返回错误
+func (w *SnowflakeWorker) NextIds(n int) ([]int64, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("idworker: invalid id count %d", n)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, 0, n)
+	err := w.reserve(int64(n), false, func(millis, first, count int64) {
+		for seq := first; seq < first+count; seq++ {
+			ids = append(ids, w.layout.compose(millis, w.dataCenterId, w.workerId, seq))
+		}
+	})
+	return ids, err
+}
+
+// reserve 预留n个序列号，每预留到一段连续的序列号就回调一次emit；block为true时时钟回拨一直等待，不返回错误
+func (w *SnowflakeWorker) reserve(n int64, block bool, emit func(millis, first, count int64)) error {
+	if w.lease != nil && w.lease.Lost() {
+		return ErrLeaseLost
+	}
+
+	seqBits := w.layout.workerShift
+	seqMax := w.layout.sequenceMax
+	var waited, pending time.Duration // 累计等待的回拨时长，尚未通知的回拨时长
+	for n > 0 {
+		old := atomic.LoadInt64(&w.state)
+		last := old>>seqBits + w.layout.epoch
+		now := w.millis()
+		if now-w.layout.epoch > w.layout.maxMillis {
+			return ErrTimeOverflow
+		}
+
+		if now < last {
+			// 时钟回拨
+			drift := time.Duration(last-now) * time.Millisecond
+			if !block && waited+drift > w.maxBackwardWait {
+				err := &ClockBackwardError{Drift: drift}
+				w.notifyBackward(ClockBackwardFailed, drift, err)
+				return err
+			}
+			// 开始等待时立即通知，阻塞等待较大的回拨时分段休眠并持续通知，监控不必等到时钟追上才发现
+			if pending == 0 || drift >= backwardWaitStep {
+				w.notifyBackward(ClockBackwardWaiting, drift, nil)
+			}
+			if drift > backwardWaitStep {
+				drift = backwardWaitStep
+			}
+			time.Sleep(drift)
+			waited += drift
+			pending += drift
+			continue
+		}
+		if pending > 0 {
+			w.notifyBackward(ClockBackwardRecovered, pending, nil)
+			pending = 0
+		}
+
+		var first int64
+		if now == last {
+			first = old&seqMax + 1
+			if first > seqMax {
+				// 当前毫秒的序列号已用完，休眠到下一毫秒，不空转消耗CPU
+				time.Sleep(time.Duration((last+1)*1e6 - w.clock().UnixNano()))
+				continue
 			}
 		}
-	} else {
-		// 如果当前时间与工作节点上一次生成ID的时间不一致 则需要重置工作节点生成ID的序号
-		w.sequence = 0
-		w.timestamp = now
+		count := seqMax - first + 1
+		if count > n {
+			count = n
+		}
+		next := (now-w.layout.epoch)<<seqBits | (first + count - 1)
+		if !atomic.CompareAndSwapInt64(&w.state, old, next) {
+			continue
+		}
+		emit(now, first, count)
+		n -= count
+	}
+	return nil
+}
+
+func (w *SnowflakeWorker) notifyBackward(state ClockBackwardState, drift time.Duration, err error) {
+	if w.onBackward != nil {
+		w.onBackward(state, drift, err)
 	}
+}
 
-	// 计算时间已经流失了xxx毫秒
-	timeFly := now - w.epoch
-	return timeFly<<timeShift | (w.dataCenterId << dataCenterShift) | (w.workerId << workerShift) | (w.sequence)
+// millis 当前时间的毫秒数
+func (w *SnowflakeWorker) millis() int64 {
+	return w.clock().UnixNano() / 1e6 // 纳秒转毫秒
 }
 
 func MaxWorkId() int64 {
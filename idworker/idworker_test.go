@@ -0,0 +1,335 @@
+package idworker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// backwardClock 在真实时钟上减去offset，调用back后模拟时钟回拨
+type backwardClock struct {
+	offset int64
+}
+
+func (c *backwardClock) now() time.Time {
+	return time.Now().Add(-time.Duration(atomic.LoadInt64(&c.offset)))
+}
+
+func (c *backwardClock) back(d time.Duration) {
+	atomic.AddInt64(&c.offset, int64(d))
+}
+
+func newTestWorker(t testing.TB) (*SnowflakeWorker, *backwardClock) {
+	w, err := NewSnowflakeWorkerWithConf(DefaultConf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &backwardClock{}
+	w.SetClock(clock.now)
+	return w, clock
+}
+
+func TestNextIdEWaitsSmallDrift(t *testing.T) {
+	w, clock := newTestWorker(t)
+	first, err := w.NextIdE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.back(5 * time.Millisecond)
+
+	second, err := w.NextIdE()
+	if err != nil {
+		t.Fatalf("drift within maxBackwardWait should be waited out, got %v", err)
+	}
+	if second <= first {
+		t.Fatalf("id went backwards: %d <= %d", second, first)
+	}
+}
+
+func TestNextIdELargeDrift(t *testing.T) {
+	w, clock := newTestWorker(t)
+	if _, err := w.NextIdE(); err != nil {
+		t.Fatal(err)
+	}
+	clock.back(time.Second)
+
+	_, err := w.NextIdE()
+	e, ok := err.(*ClockBackwardError)
+	if !ok {
+		t.Fatalf("want *ClockBackwardError, got %v", err)
+	}
+	if e.Drift < 900*time.Millisecond {
+		t.Fatalf("drift %s, want about 1s", e.Drift)
+	}
+}
+
+func TestClockBackwardHook(t *testing.T) {
+	w, clock := newTestWorker(t)
+	var states []ClockBackwardState
+	var errs []error
+	w.SetClockBackwardHook(func(state ClockBackwardState, drift time.Duration, err error) {
+		if drift <= 0 {
+			t.Errorf("state %d with drift %s", state, drift)
+		}
+		states = append(states, state)
+		errs = append(errs, err)
+	})
+	if _, err := w.NextIdE(); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.back(5 * time.Millisecond)
+	if _, err := w.NextIdE(); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0] != ClockBackwardWaiting || states[1] != ClockBackwardRecovered || errs[1] != nil {
+		t.Fatalf("want waiting then recovered, got %v %v", states, errs)
+	}
+
+	clock.back(time.Second)
+	if _, err := w.NextIdE(); err == nil {
+		t.Fatal("want error for large drift")
+	}
+	if len(states) != 3 || states[2] != ClockBackwardFailed || errs[2] == nil {
+		t.Fatalf("want hook called with error, got %v %v", states, errs)
+	}
+}
+
+func TestNextIdNotifiesWhileBlocking(t *testing.T) {
+	w, clock := newTestWorker(t)
+	waiting := make(chan time.Duration, 10)
+	w.SetClockBackwardHook(func(state ClockBackwardState, drift time.Duration, err error) {
+		if state == ClockBackwardWaiting {
+			waiting <- drift
+		}
+	})
+	first := w.NextId()
+	clock.back(time.Hour)
+
+	done := make(chan int64)
+	go func() { done <- w.NextId() }()
+	select {
+	case drift := <-waiting:
+		if drift < 59*time.Minute {
+			t.Fatalf("waiting drift %s, want about 1h", drift)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook not called while NextId is blocked")
+	}
+
+	// 时钟被校正回来后，最多再等一个休眠分段就返回
+	clock.back(-time.Hour)
+	select {
+	case id := <-done:
+		if id <= first {
+			t.Fatalf("id went backwards: %d <= %d", id, first)
+		}
+	case <-time.After(2 * backwardWaitStep):
+		t.Fatal("NextId still blocked after the clock was corrected")
+	}
+}
+
+func TestNextIdBlocksOnLargeDrift(t *testing.T) {
+	w, clock := newTestWorker(t)
+	first := w.NextId()
+	clock.back(50 * time.Millisecond)
+
+	start := time.Now()
+	second := w.NextId()
+	if second <= first {
+		t.Fatalf("id went backwards: %d <= %d", second, first)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("NextId returned after %s, want it to wait for the clock", elapsed)
+	}
+}
+
+func TestNextIdsCount(t *testing.T) {
+	w, _ := newTestWorker(t)
+	if ids, err := w.NextIds(0); err != nil || ids != nil {
+		t.Fatalf("NextIds(0) = %v, %v", ids, err)
+	}
+	if _, err := w.NextIds(-1); err == nil {
+		t.Fatal("NextIds(-1) want error")
+	}
+	ids, err := w.NextIds(1000)
+	if err != nil || len(ids) != 1000 {
+		t.Fatalf("NextIds(1000) = %d ids, %v", len(ids), err)
+	}
+}
+
+// TestConcurrentUnique 并发调用NextIdE和NextIds，所有ID不重复且每个goroutine内严格递增，需配合-race运行
+func TestConcurrentUnique(t *testing.T) {
+	w, err := NewSnowflakeWorkerWithConf(DefaultConf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const goroutines, rounds = 8, 200
+	results := make([][]int64, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if g%2 == 0 {
+					id, err := w.NextIdE()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					results[g] = append(results[g], id)
+				} else {
+					ids, err := w.NextIds(300)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					results[g] = append(results[g], ids...)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool)
+	for g, ids := range results {
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate id %d", id)
+			}
+			seen[id] = true
+			if i > 0 && id <= ids[i-1] {
+				t.Fatalf("goroutine %d: id %d not greater than %d", g, id, ids[i-1])
+			}
+		}
+	}
+}
+
+// mutexWorker 改为CAS之前的实现：互斥锁保护，当前毫秒的序列号用完时空转等待下一毫秒，作为基准测试的对照
+type mutexWorker struct {
+	mu        sync.Mutex
+	layout    layout
+	timestamp int64
+	sequence  int64
+}
+
+func (w *mutexWorker) nextId() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now().UnixNano() / 1e6
+	if now == w.timestamp {
+		w.sequence++
+		if w.sequence > w.layout.sequenceMax {
+			for now <= w.timestamp {
+				now = time.Now().UnixNano() / 1e6
+			}
+			w.sequence = 0
+		}
+	} else {
+		w.sequence = 0
+	}
+	w.timestamp = now
+	return w.layout.compose(now, 0, 0, w.sequence)
+}
+
+// benchConf 序列号位数较多，每毫秒的序列号不会用完，基准测试反映并发开销而不是每毫秒的生成上限
+var benchConf = Conf{WorkerBits: 4, SequenceBits: 20}
+
+func BenchmarkNextIdParallelMutex(b *testing.B) {
+	l, err := benchConf.layout()
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := &mutexWorker{layout: l}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.nextId()
+		}
+	})
+}
+
+func BenchmarkNextIdParallel(b *testing.B) {
+	w, err := NewSnowflakeWorkerWithConf(benchConf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := w.NextIdE(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkNextIdsParallel 每次批量生成100个，ns/op为生成100个ID的耗时
+func BenchmarkNextIdsParallel(b *testing.B) {
+	w, err := NewSnowflakeWorkerWithConf(benchConf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := w.NextIds(100); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestFakeGeneratorBeforeDefaultEpoch(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := NewFakeGenerator(start, time.Second)
+	for i := 0; i < 3; i++ {
+		id, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, err := g.Timestamp(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := start.Add(time.Duration(i) * time.Second); !ts.Equal(want) {
+			t.Fatalf("id %s at %s, want %s", id, ts, want)
+		}
+	}
+}
+
+func TestConfRejectsOverflowingEpoch(t *testing.T) {
+	conf := Conf{DataCenterBits: 2, WorkerBits: 10, SequenceBits: 12, Epoch: 1}
+	if err := conf.Validate(); err == nil {
+		t.Fatal("epoch 1 with 39 time bits overflows, want error")
+	}
+	conf.Epoch = time.Now().AddDate(-1, 0, 0).UnixNano() / 1e6
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewSnowflakeWorkerWithConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := w.NextIdE()
+	if err != nil || id <= 0 {
+		t.Fatalf("NextIdE() = %d, %v", id, err)
+	}
+}
+
+func TestFakeGeneratorDecodesWithTimeOf(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := NewFakeGenerator(start, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		id, err := g.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, err := TimeOf(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := start.Add(time.Duration(i) * time.Millisecond); !ts.Equal(want) {
+			t.Fatalf("TimeOf(%s) = %s, want %s", id, ts, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package idworker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// 租约相关的错误
+var (
+	ErrNoFreeWorkerId = errors.New("idworker: no free worker id")
+	ErrLeaseLost      = errors.New("idworker: worker id lease lost")
+)
+
+// LeaseConf 从redis租用workerId的相关配置
+type LeaseConf struct {
+	Key         string `yaml:"key"`         // 记录租约的hash key，默认idworker:lease
+	TTL         int    `yaml:"ttl"`         // 租约有效期，单位：秒，默认30
+	Heartbeat   int    `yaml:"heartbeat"`   // 续约间隔，单位：秒，默认TTL/3
+	MaxWorkerId int64  `yaml:"maxWorkerId"` // 可分配的最大workerId，默认按默认位分配计算，自定义位分配时取Conf.MaxWorkerId()
+}
+
+// 租约保存在一个hash中，field为workerId，value为"持有者|到期毫秒"，单个key在集群模式下也能保证原子性
+const (
+	// KEYS[1] 租约hash ARGV[1] 持有者 ARGV[2] 当前毫秒 ARGV[3] 租期毫秒 ARGV[4] 起始workerId ARGV[5] 最大workerId
+	acquireScript = `
+local now = tonumber(ARGV[2])
+local max = tonumber(ARGV[5])
+local start = tonumber(ARGV[4])
+for i = 0, max do
+	local id = (start + i) % (max + 1)
+	local v = redis.call('HGET', KEYS[1], id)
+	if not v or tonumber(string.match(v, '|(%d+)$')) < now then
+		redis.call('HSET', KEYS[1], id, ARGV[1] .. '|' .. (now + tonumber(ARGV[3])))
+		return id
+	end
+end
+return -1`
+	// KEYS[1] 租约hash ARGV[1] 持有者 ARGV[2] 当前毫秒 ARGV[3] 租期毫秒 ARGV[4] workerId
+	renewScript = `
+local v = redis.call('HGET', KEYS[1], ARGV[4])
+if not v or string.match(v, '^(.*)|') ~= ARGV[1] then return 0 end
+redis.call('HSET', KEYS[1], ARGV[4], ARGV[1] .. '|' .. (tonumber(ARGV[2]) + tonumber(ARGV[3])))
+return 1`
+	// KEYS[1] 租约hash ARGV[1] 持有者 ARGV[2] workerId
+	releaseScript = `
+local v = redis.call('HGET', KEYS[1], ARGV[2])
+if v and string.match(v, '^(.*)|') == ARGV[1] then return redis.call('HDEL', KEYS[1], ARGV[2]) end
+return 0`
+)
+
+var (
+	acquire = goredis.NewScript(acquireScript)
+	renew   = goredis.NewScript(renewScript)
+	release = goredis.NewScript(releaseScript)
+)
+
+// WorkerLease 从redis租用的workerId，后台定时续约，续约失败超过租期后视为丢失
+type WorkerLease struct {
+	conf     LeaseConf
+	rdb      *redis.Client
+	key      string
+	owner    string
+	workerId int64
+
+	lost      int32 // 租约是否已丢失
+	renewedAt int64 // 最后一次续约成功的毫秒数
+	onLost    func(workerId int64)
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// AcquireWorkerLease 从redis中租用一个空闲的workerId并启动续约
+// onLost在租约丢失时回调，可以为nil
+func AcquireWorkerLease(ctx context.Context, conf LeaseConf, rdb *redis.Client, onLost func(workerId int64)) (*WorkerLease, error) {
+	if conf.Key == "" {
+		conf.Key = "idworker:lease"
+	}
+	if conf.TTL <= 0 {
+		conf.TTL = 30
+	}
+	if conf.Heartbeat <= 0 || conf.Heartbeat >= conf.TTL {
+		conf.Heartbeat = conf.TTL / 3
+		if conf.Heartbeat == 0 {
+			conf.Heartbeat = 1
+		}
+	}
+	if conf.MaxWorkerId <= 0 {
+		conf.MaxWorkerId = workerMax
+	}
+
+	owner, err := leaseOwner()
+	if err != nil {
+		return nil, err
+	}
+	start, err := rand.Int(rand.Reader, big.NewInt(conf.MaxWorkerId+1))
+	if err != nil {
+		return nil, err
+	}
+
+	l := &WorkerLease{
+		conf:   conf,
+		rdb:    rdb,
+		key:    rdb.WrapKey(conf.Key),
+		owner:  owner,
+		onLost: onLost,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	now := nowMillis()
+	id, err := acquire.Run(ctx, rdb, []string{l.key}, owner, now, l.ttlMillis(), start.Int64(), conf.MaxWorkerId).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if id < 0 {
+		return nil, ErrNoFreeWorkerId
+	}
+	l.workerId = id
+	l.renewedAt = now
+
+	go l.heartbeat()
+	return l, nil
+}
+
+// WorkerId 租用到的workerId
+func (l *WorkerLease) WorkerId() WorkerId {
+	return WorkerId(l.workerId)
+}
+
+// Lost 租约是否已丢失，丢失后不能再使用该workerId生成ID
+// 调用时按最后一次续约成功的时间判断，不依赖心跳，续约卡住时也能及时发现租约即将到期
+func (l *WorkerLease) Lost() bool {
+	return atomic.LoadInt32(&l.lost) == 1 || nowMillis()-atomic.LoadInt64(&l.renewedAt) >= l.validMillis()
+}
+
+// Release 停止续约并归还workerId，服务关闭时调用
+func (l *WorkerLease) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	atomic.StoreInt32(&l.lost, 1)
+	return release.Run(ctx, l.rdb, []string{l.key}, l.owner, l.workerId).Err()
+}
+
+// heartbeat 定时续约；被其他实例抢占，或连续续约失败超过有效时间时标记为丢失
+func (l *WorkerLease) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(time.Duration(l.conf.Heartbeat) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			// 续约的超时不超过租约剩余的有效时间，避免请求卡住时租约在续约期间到期
+			now := nowMillis()
+			timeout := atomic.LoadInt64(&l.renewedAt) + l.validMillis() - now
+			if timeout <= 0 {
+				l.markLost()
+				return
+			}
+			if max := int64(l.conf.Heartbeat) * 1000; timeout > max {
+				timeout = max
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Millisecond)
+			ok, err := renew.Run(ctx, l.rdb, []string{l.key}, l.owner, now, l.ttlMillis(), l.workerId).Int64()
+			cancel()
+			if err == nil && ok == 1 {
+				atomic.StoreInt64(&l.renewedAt, now)
+				continue
+			}
+			if err == nil || nowMillis()-atomic.LoadInt64(&l.renewedAt) >= l.validMillis() {
+				l.markLost()
+				return
+			}
+		}
+	}
+}
+
+func (l *WorkerLease) markLost() {
+	if atomic.CompareAndSwapInt32(&l.lost, 0, 1) && l.onLost != nil {
+		l.onLost(l.workerId)
+	}
+}
+
+func (l *WorkerLease) ttlMillis() int64 {
+	return int64(l.conf.TTL) * 1000
+}
+
+// validMillis 续约成功后可以放心使用的时长，比租期少留十分之一，抵消各实例间的时钟偏差
+func (l *WorkerLease) validMillis() int64 {
+	return l.ttlMillis() - l.ttlMillis()/10
+}
+
+// leaseOwner 生成租约持有者标识：主机名:进程号:随机串
+func leaseOwner() (string, error) {
+	host, _ := os.Hostname()
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b)), nil
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / 1e6
+}
@@ -0,0 +1,226 @@
+package idworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/types"
+	"gorm.io/gorm"
+)
+
+// 号段相关的错误
+var (
+	ErrBizTagNotFound = errors.New("idworker: biz tag not found")             // 号段表中没有该业务标识
+	ErrInvalidStep    = errors.New("idworker: segment step must be positive") // 号段表中该业务标识的步长不大于0
+)
+
+// IdAlloc 号段分配表，每个业务标识一行，max_id为已分配出去的最大ID
+type IdAlloc struct {
+	BizTag      string     `gorm:"primaryKey;size:128"` // 业务标识
+	MaxId       int64      `gorm:"not null;default:1"`  // 已分配的最大ID
+	Step        int64      `gorm:"not null"`            // 号段的初始步长，动态调整时不会小于该值
+	Description string     `gorm:"size:256"`            // 描述
+	UpdatedAt   types.Time // 更新时间
+}
+
+func (IdAlloc) TableName() string {
+	return "id_alloc"
+}
+
+// SegmentConf 号段模式相关配置
+type SegmentConf struct {
+	PrefetchRatio   float64 `yaml:"prefetchRatio"`   // 当前号段使用到该比例时异步加载下一个号段，默认0.8
+	SegmentDuration int     `yaml:"segmentDuration"` // 期望一个号段使用的时长，单位：秒，默认900，据此动态调整步长
+	MaxStep         int64   `yaml:"maxStep"`         // 动态调整时的最大步长，默认1000000
+}
+
+// segment 号段[start, max)
+type segment struct {
+	start int64
+	value int64 // 下一个可用的ID
+	max   int64
+}
+
+// segmentBuffer 一个业务标识的双号段缓存
+type segmentBuffer struct {
+	initOnce sync.Once // 首次使用时加载第一个号段，只阻塞同一业务标识的调用方
+	initErr  error     // 加载第一个号段失败的错误
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	current *segment
+	next    *segment
+	loading bool  // 是否正在加载下一个号段
+	loadErr error // 最近一次加载失败的错误
+
+	step     int64     // 当前步长
+	minStep  int64     // 数据库中配置的步长
+	loadedAt time.Time // 上一次加载号段的时间
+}
+
+// SegmentGenerator 号段模式ID生成器(Leaf-segment)
+// 从数据库按业务标识批量领取号段，在内存中分配；不依赖时钟，生成的ID连续递增
+type SegmentGenerator struct {
+	conf    SegmentConf
+	db      *gorm.DB
+	mu      sync.Mutex
+	buffers map[string]*segmentBuffer
+}
+
+func NewSegmentGenerator(conf SegmentConf, db *gorm.DB) *SegmentGenerator {
+	if conf.PrefetchRatio <= 0 || conf.PrefetchRatio >= 1 {
+		conf.PrefetchRatio = 0.8
+	}
+	if conf.SegmentDuration <= 0 {
+		conf.SegmentDuration = 900
+	}
+	if conf.MaxStep <= 0 {
+		conf.MaxStep = 1000000
+	}
+	return &SegmentGenerator{conf: conf, db: db, buffers: map[string]*segmentBuffer{}}
+}
+
+// AutoMigrate 创建号段分配表
+func (g *SegmentGenerator) AutoMigrate() error {
+	return g.db.AutoMigrate(&IdAlloc{})
+}
+
+// NextId 获取业务标识的下一个ID
+func (g *SegmentGenerator) NextId(ctx context.Context, bizTag string) (int64, error) {
+	buf, err := g.buffer(ctx, bizTag)
+	if err != nil {
+		return 0, err
+	}
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	for {
+		seg := buf.current
+		if buf.next == nil && !buf.loading &&
+			float64(seg.value-seg.start) >= g.conf.PrefetchRatio*float64(seg.max-seg.start) {
+			buf.loading = true
+			go g.prefetch(bizTag, buf)
+		}
+		if seg.value < seg.max {
+			id := seg.value
+			seg.value++
+			return id, nil
+		}
+		if buf.next != nil {
+			buf.current, buf.next = buf.next, nil
+			continue
+		}
+		if buf.loadErr != nil {
+			err, buf.loadErr = buf.loadErr, nil
+			return 0, err
+		}
+		// 当前号段已用完，等待下一个号段加载完成
+		buf.cond.Wait()
+	}
+}
+
+// buffer 获取业务标识的号段缓存，首次使用时同步加载第一个号段
+// 加载时不持有全局锁，某个业务标识首次加载较慢不影响其他业务标识；加载失败时移除缓存，下次调用重新加载
+func (g *SegmentGenerator) buffer(ctx context.Context, bizTag string) (*segmentBuffer, error) {
+	g.mu.Lock()
+	buf, ok := g.buffers[bizTag]
+	if !ok {
+		buf = &segmentBuffer{}
+		buf.cond = sync.NewCond(&buf.mu)
+		g.buffers[bizTag] = buf
+	}
+	g.mu.Unlock()
+
+	buf.initOnce.Do(func() {
+		seg, dbStep, err := g.fetch(ctx, bizTag, 0)
+		if err != nil {
+			buf.initErr = err
+			g.mu.Lock()
+			if g.buffers[bizTag] == buf {
+				delete(g.buffers, bizTag)
+			}
+			g.mu.Unlock()
+			return
+		}
+		buf.current, buf.step, buf.minStep, buf.loadedAt = seg, dbStep, dbStep, time.Now()
+	})
+	if buf.initErr != nil {
+		return nil, buf.initErr
+	}
+	return buf, nil
+}
+
+// prefetch 异步加载下一个号段，并根据上一个号段的消耗速度调整步长
+func (g *SegmentGenerator) prefetch(bizTag string, buf *segmentBuffer) {
+	buf.mu.Lock()
+	step := g.nextStep(buf)
+	buf.mu.Unlock()
+
+	seg, dbStep, err := g.fetch(context.Background(), bizTag, step)
+
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.loading = false
+	if err != nil {
+		buf.loadErr = fmt.Errorf("idworker: load segment of %s: %w", bizTag, err)
+	} else {
+		buf.next = seg
+		buf.loadErr = nil
+		buf.step = step
+		buf.minStep = dbStep
+		buf.loadedAt = time.Now()
+	}
+	buf.cond.Broadcast()
+}
+
+// nextStep 上一个号段消耗过快则步长翻倍，过慢则减半，但不小于数据库中配置的步长
+func (g *SegmentGenerator) nextStep(buf *segmentBuffer) int64 {
+	expected := time.Duration(g.conf.SegmentDuration) * time.Second
+	elapsed := time.Since(buf.loadedAt)
+	step := buf.step
+	if elapsed < expected && step*2 <= g.conf.MaxStep {
+		step *= 2
+	} else if elapsed > 2*expected && step/2 >= buf.minStep {
+		step /= 2
+	}
+	return step
+}
+
+// fetch 在事务中原子地将max_id增加step并读取，得到号段[max_id-step, max_id)；step为0时使用数据库中的步长
+// 数据库中的步长必须大于0，否则号段为空，NextId会不停地加载
+func (g *SegmentGenerator) fetch(ctx context.Context, bizTag string, step int64) (*segment, int64, error) {
+	var alloc IdAlloc
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expr := gorm.Expr("max_id + step")
+		if step > 0 {
+			expr = gorm.Expr("max_id + ?", step)
+		}
+		res := tx.Model(&IdAlloc{}).Where("biz_tag = ? AND step > 0", bizTag).
+			Updates(map[string]interface{}{"max_id": expr, "updated_at": types.NowTime()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var n int64
+			if err := tx.Model(&IdAlloc{}).Where("biz_tag = ?", bizTag).Count(&n).Error; err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrBizTagNotFound
+			}
+			return fmt.Errorf("%w: %s", ErrInvalidStep, bizTag)
+		}
+		return tx.Where("biz_tag = ?", bizTag).Take(&alloc).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if step == 0 {
+		step = alloc.Step
+	}
+	start := alloc.MaxId - step
+	return &segment{start: start, value: start, max: alloc.MaxId}, alloc.Step, nil
+}
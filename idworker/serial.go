@@ -0,0 +1,161 @@
+package idworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	"github.com/18689221165/lynn-toolkit/types"
+	goredis "github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ResetPeriod 序列号的重置周期
+type ResetPeriod string
+
+const (
+	ResetDaily   ResetPeriod = "day"   // 每天重置
+	ResetMonthly ResetPeriod = "month" // 每月重置
+	ResetYearly  ResetPeriod = "year"  // 每年重置
+	ResetNever   ResetPeriod = "never" // 从不重置
+)
+
+// SerialFormat 业务流水号格式：前缀+日期+补零的序列号，如PO20261018000123
+type SerialFormat struct {
+	Prefix     string      `yaml:"prefix"`     // 前缀
+	DateLayout string      `yaml:"dateLayout"` // 日期格式，默认20060102
+	Width      int         `yaml:"width"`      // 序列号补零后的宽度，默认6
+	Reset      ResetPeriod `yaml:"reset"`      // 重置周期，默认每天
+
+	// FallbackOffset redis与数据库计数器的分界：redis只分配[1, FallbackOffset]，数据库从FallbackOffset+1开始，
+	// 两段不重叠，redis多次不可用也不会重复；redis的计数超过该值时同样改用数据库计数器
+	// 默认为宽度的一半，如宽度6时redis分配1~500000，数据库从500001开始
+	FallbackOffset int64 `yaml:"fallbackOffset"`
+}
+
+// SerialConf 业务流水号相关配置
+type SerialConf struct {
+	KeyPrefix string                  `yaml:"keyPrefix"` // 计数器在redis中的key前缀，默认serial
+	Formats   map[string]SerialFormat `yaml:"formats"`   // 业务名 -> 流水号格式
+}
+
+// SerialCounter redis不可用时使用的数据库计数器
+type SerialCounter struct {
+	Name      string     `gorm:"primaryKey;size:64"` // 业务名
+	Period    string     `gorm:"primaryKey;size:16"` // 周期，如20261018
+	Value     int64      `gorm:"not null"`           // 当前计数
+	UpdatedAt types.Time // 更新时间
+}
+
+// errRedisRangeFull redis计数超过了FallbackOffset，继续使用会与数据库计数器重叠
+var errRedisRangeFull = errors.New("idworker: serial redis range exhausted")
+
+// SerialGenerator 业务流水号生成器
+// 计数器保存在redis中并按周期过期，redis不可用时降级使用数据库计数器
+// redis的计数器需要开启持久化，redis丢失数据后计数从1重新开始，会与之前分配的流水号重复
+type SerialGenerator struct {
+	conf SerialConf
+	rdb  *redis.Client
+	db   *gorm.DB // 可以为nil，此时不降级
+}
+
+func NewSerialGenerator(conf SerialConf, rdb *redis.Client, db *gorm.DB) *SerialGenerator {
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "serial"
+	}
+	return &SerialGenerator{conf: conf, rdb: rdb, db: db}
+}
+
+// AutoMigrate 创建数据库计数器表
+func (g *SerialGenerator) AutoMigrate() error {
+	return g.db.AutoMigrate(&SerialCounter{})
+}
+
+// Next 生成业务name的下一个流水号
+func (g *SerialGenerator) Next(ctx context.Context, name string) (string, error) {
+	f, ok := g.conf.Formats[name]
+	if !ok {
+		return "", fmt.Errorf("idworker: serial format %s not configured", name)
+	}
+	if f.DateLayout == "" {
+		f.DateLayout = "20060102"
+	}
+	if f.Width <= 0 {
+		f.Width = 6
+	}
+	if f.FallbackOffset <= 0 {
+		f.FallbackOffset = 5
+		for i := 1; i < f.Width; i++ {
+			f.FallbackOffset *= 10
+		}
+	}
+
+	now := time.Now()
+	period, expireAt := periodOf(now, f.Reset)
+	seq, err := g.incrRedis(ctx, name, period, expireAt, f.FallbackOffset)
+	if err != nil {
+		if g.db == nil {
+			return "", err
+		}
+		if seq, err = g.incrDB(ctx, name, period, f.FallbackOffset); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s%s%0*d", f.Prefix, now.Format(f.DateLayout), f.Width, seq), nil
+}
+
+// incrRedis redis计数器自增，计数超过limit后返回errRedisRangeFull
+func (g *SerialGenerator) incrRedis(ctx context.Context, name, period string, expireAt, limit int64) (int64, error) {
+	key := g.rdb.WrapKey(g.conf.KeyPrefix + ":" + name + ":" + period)
+	var incr *goredis.IntCmd
+	_, err := g.rdb.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		if expireAt > 0 {
+			pipe.ExpireAt(ctx, key, time.Unix(expireAt, 0))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if seq := incr.Val(); seq <= limit {
+		return seq, nil
+	}
+	return 0, errRedisRangeFull
+}
+
+// incrDB 数据库计数器自增，使用upsert保证原子性，周期内首次使用时从offset+1开始，只在offset之后增长
+func (g *SerialGenerator) incrDB(ctx context.Context, name, period string, offset int64) (int64, error) {
+	var counter SerialCounter
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{"value": gorm.Expr("value + 1"), "updated_at": types.NowTime()}),
+		}).Create(&SerialCounter{Name: name, Period: period, Value: offset + 1, UpdatedAt: types.NowTime()}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("name = ? AND period = ?", name, period).Take(&counter).Error
+	})
+	return counter.Value, err
+}
+
+// periodOf 计算当前所处的周期标识，以及计数器的过期时间(周期结束后再保留一天)，不重置时过期时间为0
+func periodOf(now time.Time, reset ResetPeriod) (string, int64) {
+	y, m, d := now.Date()
+	switch reset {
+	case ResetNever:
+		return "all", 0
+	case ResetYearly:
+		end := time.Date(y+1, 1, 1, 0, 0, 0, 0, now.Location())
+		return now.Format("2006"), end.AddDate(0, 0, 1).Unix()
+	case ResetMonthly:
+		end := time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location())
+		return now.Format("200601"), end.AddDate(0, 0, 1).Unix()
+	default:
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+		return now.Format("20060102"), end.AddDate(0, 0, 1).Unix()
+	}
+}
@@ -0,0 +1,90 @@
+package idworker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestSerial 两个实例共享同一个redis和数据库，redis可通过mr.SetError模拟不可用
+func newTestSerial(t *testing.T, format SerialFormat) (*miniredis.Miniredis, []*SerialGenerator) {
+	mr := miniredis.RunT(t)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := SerialConf{Formats: map[string]SerialFormat{"po": format}}
+	var gens []*SerialGenerator
+	for i := 0; i < 2; i++ {
+		rdb := redis.NewRedisClient(redis.Conf{Addrs: []string{mr.Addr()}, Timeout: 1})
+		t.Cleanup(rdb.Destroy)
+		gens = append(gens, NewSerialGenerator(conf, rdb, db))
+	}
+	if err := gens[0].AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	return mr, gens
+}
+
+func TestSerialOutageRecoveryOutage(t *testing.T) {
+	mr, gens := newTestSerial(t, SerialFormat{Prefix: "PO", DateLayout: "-", Width: 3, FallbackOffset: 100})
+	seen := map[string]bool{}
+	next := func(g *SerialGenerator, n int) {
+		for i := 0; i < n; i++ {
+			s, err := g.Next(context.Background(), "po")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[s] {
+				t.Fatalf("serial %s issued twice", s)
+			}
+			seen[s] = true
+		}
+	}
+
+	next(gens[0], 5)
+	mr.SetError("down")
+	next(gens[0], 3)
+	next(gens[1], 3)
+	mr.SetError("")
+	// 恢复后redis从原来的计数继续，第二个实例从未见过redis的计数
+	next(gens[0], 20)
+	next(gens[1], 20)
+	mr.SetError("down")
+	next(gens[1], 5)
+	next(gens[0], 5)
+	mr.SetError("")
+	next(gens[0], 5)
+
+	for _, s := range []string{"PO-001", "PO-050", "PO-101", "PO-116"} {
+		if !seen[s] {
+			t.Errorf("want %s issued", s)
+		}
+	}
+	if seen["PO-117"] || seen["PO-051"] {
+		t.Errorf("unexpected serials: %v", seen)
+	}
+}
+
+func TestSerialRedisRangeFull(t *testing.T) {
+	_, gens := newTestSerial(t, SerialFormat{Prefix: "PO", DateLayout: "-", Width: 2, FallbackOffset: 3})
+	var got []string
+	for i := 0; i < 5; i++ {
+		s, err := gens[i%2].Next(context.Background(), "po")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	want := []string{"PO-01", "PO-02", "PO-03", "PO-04", "PO-05"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
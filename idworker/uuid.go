@@ -0,0 +1,168 @@
+package idworker
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	uuidLen = 36
+	ulidLen = 26
+)
+
+// timeRandom UUIDv7和ULID共用的结构：48位毫秒时间戳 + 随机熵
+// 同一毫秒内(或时钟回拨时)在上一次的熵上加1，保证同一生成器生成的ID严格递增
+type timeRandom struct {
+	mu     sync.Mutex
+	clock  func() time.Time
+	hiMask uint16 // 熵高位的掩码，熵总位数为高位+64
+	last   int64  // 上一次使用的毫秒数
+	hi     uint16 // 熵的高位
+	lo     uint64 // 熵的低64位
+}
+
+func (g *timeRandom) next() (millis int64, hi uint16, lo uint64, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock().UnixNano() / 1e6
+	if now <= g.last {
+		g.lo++
+		if g.lo != 0 || g.hi < g.hiMask {
+			if g.lo == 0 {
+				g.hi++
+			}
+			return g.last, g.hi, g.lo, nil
+		}
+		// 熵已用尽，借用下一毫秒
+		now = g.last + 1
+	}
+
+	var b [10]byte
+	if _, err = rand.Read(b[:]); err != nil {
+		return 0, 0, 0, err
+	}
+	// 最高位清零，为同一毫秒内的递增留出空间
+	g.hi = binary.BigEndian.Uint16(b[:2]) & (g.hiMask >> 1)
+	g.lo = binary.BigEndian.Uint64(b[2:])
+	g.last = now
+	return g.last, g.hi, g.lo, nil
+}
+
+// UUIDv7 按RFC 9562生成版本7的UUID：48位毫秒时间戳 + 74位随机数，按字典序即按时间排序，适合作为数据库主键
+type UUIDv7 struct {
+	gen timeRandom
+}
+
+func NewUUIDv7() *UUIDv7 {
+	return &UUIDv7{gen: timeRandom{clock: time.Now, hiMask: 1<<10 - 1}}
+}
+
+// SetClock 替换时钟，需在生成ID之前设置
+func (u *UUIDv7) SetClock(clock func() time.Time) {
+	u.gen.clock = clock
+}
+
+// Generate 生成小写带连字符的UUID，如0192a3b4-c5d6-7e8f-9a0b-1c2d3e4f5a6b
+func (u *UUIDv7) Generate() (string, error) {
+	millis, hi, lo, err := u.gen.next()
+	if err != nil {
+		return "", err
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[0:], uint64(millis)<<16)
+	randA := hi<<2 | uint16(lo>>62)
+	binary.BigEndian.PutUint16(b[6:], 0x7000|randA)
+	binary.BigEndian.PutUint64(b[8:], 0x8000000000000000|lo&(1<<62-1))
+
+	buf := make([]byte, uuidLen)
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf), nil
+}
+
+func (u *UUIDv7) Timestamp(id string) (time.Time, error) {
+	return uuidTime(id)
+}
+
+// uuidTime 解析UUIDv7的生成时间，其他版本的UUID返回ErrInvalidId
+func uuidTime(id string) (time.Time, error) {
+	if len(id) != uuidLen || id[8] != '-' || id[13] != '-' || id[18] != '-' || id[23] != '-' || id[14] != '7' {
+		return time.Time{}, ErrInvalidId
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(b) != 16 {
+		return time.Time{}, ErrInvalidId
+	}
+	millis := int64(binary.BigEndian.Uint64(b) >> 16)
+	return time.Unix(0, millis*1e6), nil
+}
+
+// ULID 生成26位Crockford base32编码的ULID：48位毫秒时间戳 + 80位随机数，按字典序即按时间排序
+type ULID struct {
+	gen timeRandom
+}
+
+func NewULID() *ULID {
+	return &ULID{gen: timeRandom{clock: time.Now, hiMask: 1<<16 - 1}}
+}
+
+// SetClock 替换时钟，需在生成ID之前设置
+func (u *ULID) SetClock(clock func() time.Time) {
+	u.gen.clock = clock
+}
+
+func (u *ULID) Generate() (string, error) {
+	millis, hi, lo, err := u.gen.next()
+	if err != nil {
+		return "", err
+	}
+	// 128位按5位一组从低位开始编码，首字符只有3位
+	h, l := uint64(millis)<<16|uint64(hi), lo
+	buf := make([]byte, ulidLen)
+	for i := ulidLen - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[l&31]
+		l = l>>5 | h<<59
+		h >>= 5
+	}
+	return string(buf), nil
+}
+
+func (u *ULID) Timestamp(id string) (time.Time, error) {
+	return ulidTime(id)
+}
+
+// ulidTime 解析ULID的生成时间，不区分大小写
+func ulidTime(id string) (time.Time, error) {
+	if len(id) != ulidLen || id[0] > '7' {
+		return time.Time{}, ErrInvalidId
+	}
+	var h, l uint64
+	for i := 0; i < ulidLen; i++ {
+		n := strings.IndexByte(crockfordAlphabet, upper(id[i]))
+		if n < 0 {
+			return time.Time{}, ErrInvalidId
+		}
+		h = h<<5 | l>>59
+		l = l<<5 | uint64(n)
+	}
+	return time.Unix(0, int64(h>>16)*1e6), nil
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
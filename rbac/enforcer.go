@@ -0,0 +1,170 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// Conf 权限策略缓存相关配置
+type Conf struct {
+	CacheKey        string `yaml:"cacheKey"`        // 策略在redis中的缓存key，默认rbac:policy
+	CacheTTL        int    `yaml:"cacheTTL"`        // 策略缓存时间，单位：秒，默认3600
+	RefreshInterval int    `yaml:"refreshInterval"` // 检查策略版本的间隔，单位：秒，默认10
+}
+
+// Enforcer 权限校验器
+// 策略保存在内存中；配置了redis时，策略会缓存到redis，并通过版本号感知其他实例的变更
+type Enforcer struct {
+	mu         sync.RWMutex
+	refreshing int32 // 是否有goroutine正在检查版本号
+	conf       Conf
+	loader     Loader
+	rdb        *redis.Client
+
+	perms     map[string][]string // 角色 -> 展开继承后的权限
+	version   int64               // 当前策略的版本号
+	checkedAt time.Time           // 上一次检查版本号的时间
+}
+
+// NewEnforcer 创建权限校验器，rdb可以为nil，此时不使用redis缓存
+func NewEnforcer(conf Conf, loader Loader, rdb *redis.Client) (*Enforcer, error) {
+	if conf.CacheKey == "" {
+		conf.CacheKey = "rbac:policy"
+	}
+	if conf.CacheTTL <= 0 {
+		conf.CacheTTL = 3600
+	}
+	if conf.RefreshInterval <= 0 {
+		conf.RefreshInterval = 10
+	}
+
+	e := &Enforcer{conf: conf, loader: loader, rdb: rdb}
+	if err := e.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Allow 判断角色是否拥有权限
+func (e *Enforcer) Allow(ctx context.Context, role, permission string) bool {
+	e.refreshIfStale(ctx)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, pattern := range e.perms[role] {
+		if Match(pattern, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions 返回角色拥有的全部权限（包括继承的）
+func (e *Enforcer) Permissions(ctx context.Context, role string) []string {
+	e.refreshIfStale(ctx)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]string{}, e.perms[role]...)
+}
+
+// Reload 重新加载策略，优先读取redis缓存，缓存不存在时从Loader加载并写入缓存
+func (e *Enforcer) Reload(ctx context.Context) error {
+	var version int64
+	policy := &Policy{}
+	if e.rdb != nil {
+		version, _ = e.rdb.Get(ctx, e.versionKey()).Int64()
+		data, err := e.rdb.Get(ctx, e.cacheKey()).Bytes()
+		if err != nil && err != goredis.Nil {
+			return err
+		}
+		if err == nil && json.Unmarshal(data, policy) == nil {
+			return e.apply(policy, version)
+		}
+	}
+
+	policy, err := e.loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if e.rdb != nil {
+		data, _ := json.Marshal(policy)
+		ttl := time.Duration(e.conf.CacheTTL) * time.Second
+		if err = e.rdb.Set(ctx, e.cacheKey(), data, ttl).Err(); err != nil {
+			return err
+		}
+	}
+	return e.apply(policy, version)
+}
+
+// Invalidate 策略变更后调用，清除redis缓存并递增版本号，其他实例会在RefreshInterval内重新加载
+func (e *Enforcer) Invalidate(ctx context.Context) error {
+	if e.rdb != nil {
+		if err := e.rdb.Del(ctx, e.cacheKey()).Err(); err != nil {
+			return err
+		}
+		if err := e.rdb.Incr(ctx, e.versionKey()).Err(); err != nil {
+			return err
+		}
+	}
+	return e.Reload(ctx)
+}
+
+// apply 编译策略并替换内存中的数据
+func (e *Enforcer) apply(policy *Policy, version int64) error {
+	perms, err := policy.compile()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.perms = perms
+	e.version = version
+	e.checkedAt = time.Now()
+	e.mu.Unlock()
+	return nil
+}
+
+// refreshIfStale 每隔RefreshInterval检查一次redis中的版本号，有变化则重新加载
+func (e *Enforcer) refreshIfStale(ctx context.Context) {
+	if e.rdb == nil {
+		return
+	}
+	e.mu.RLock()
+	fresh := time.Since(e.checkedAt) < time.Duration(e.conf.RefreshInterval)*time.Second
+	e.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	// 只允许一个goroutine去检查，其他goroutine继续使用旧策略
+	if !atomic.CompareAndSwapInt32(&e.refreshing, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&e.refreshing, 0)
+
+	version, err := e.rdb.Get(ctx, e.versionKey()).Int64()
+	if err != nil && err != goredis.Nil {
+		return
+	}
+	e.mu.Lock()
+	changed := version != e.version
+	e.checkedAt = time.Now()
+	e.mu.Unlock()
+	if changed {
+		_ = e.Reload(ctx)
+	}
+}
+
+func (e *Enforcer) cacheKey() string {
+	return e.rdb.WrapKey(e.conf.CacheKey)
+}
+
+func (e *Enforcer) versionKey() string {
+	return e.rdb.WrapKey(e.conf.CacheKey + ":version")
+}
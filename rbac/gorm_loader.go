@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/18689221165/lynn-toolkit/database"
+	"gorm.io/gorm"
+)
+
+// RolePermission 角色权限表
+type RolePermission struct {
+	database.Model
+	Role       string `gorm:"size:64;index:idx_role"` // 角色名
+	Permission string `gorm:"size:128"`               // 权限，格式 resource:action
+}
+
+// RoleInherit 角色继承表
+type RoleInherit struct {
+	database.Model
+	Role   string `gorm:"size:64;index:idx_role"` // 角色名
+	Parent string `gorm:"size:64"`                // 继承的父角色
+}
+
+// Loader 策略加载器
+type Loader interface {
+	Load(ctx context.Context) (*Policy, error)
+}
+
+// YamlLoader 从yaml文件加载策略
+type YamlLoader string
+
+func (path YamlLoader) Load(_ context.Context) (*Policy, error) {
+	return LoadYaml(string(path))
+}
+
+// GormLoader 通过gorm从数据库加载策略
+type GormLoader struct {
+	db *gorm.DB
+}
+
+func NewGormLoader(db *gorm.DB) *GormLoader {
+	return &GormLoader{db: db}
+}
+
+// AutoMigrate 创建角色权限相关的表
+func (l *GormLoader) AutoMigrate() error {
+	return l.db.AutoMigrate(&RolePermission{}, &RoleInherit{})
+}
+
+func (l *GormLoader) Load(ctx context.Context) (*Policy, error) {
+	var perms []RolePermission
+	if err := l.db.WithContext(ctx).Find(&perms).Error; err != nil {
+		return nil, err
+	}
+	var inherits []RoleInherit
+	if err := l.db.WithContext(ctx).Find(&inherits).Error; err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	for _, p := range perms {
+		policy.Roles = append(policy.Roles, Role{Name: p.Role, Permissions: []string{p.Permission}})
+	}
+	for _, i := range inherits {
+		policy.Roles = append(policy.Roles, Role{Name: i.Role, Inherits: []string{i.Parent}})
+		// 父角色可能没有直接授权的记录，这里补一个空定义
+		policy.Roles = append(policy.Roles, Role{Name: i.Parent})
+	}
+	return policy, nil
+}
@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Role 角色定义
+type Role struct {
+	Name        string   `yaml:"name" json:"name"`               // 角色名，与jwt中的Role对应
+	Inherits    []string `yaml:"inherits" json:"inherits"`       // 继承的父角色
+	Permissions []string `yaml:"permissions" json:"permissions"` // 权限列表，格式 resource:action，支持*通配
+}
+
+// Policy 角色权限策略
+//
+//	roles:
+//	  - name: admin
+//	    inherits: [operator]
+//	    permissions: ["*"]
+//	  - name: operator
+//	    permissions: ["order:*", "user:read"]
+type Policy struct {
+	Roles []Role `yaml:"roles" json:"roles"`
+}
+
+// LoadYaml 从yaml文件中加载策略
+func LoadYaml(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &Policy{}
+	if err = yaml.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// compile 展开角色继承关系，得到每个角色最终拥有的权限
+func (p *Policy) compile() (map[string][]string, error) {
+	roles := make(map[string]Role, len(p.Roles))
+	for _, r := range p.Roles {
+		if exist, ok := roles[r.Name]; ok {
+			// 同名角色合并，便于从数据库逐行加载
+			exist.Inherits = append(exist.Inherits, r.Inherits...)
+			exist.Permissions = append(exist.Permissions, r.Permissions...)
+			roles[r.Name] = exist
+			continue
+		}
+		roles[r.Name] = r
+	}
+
+	result := make(map[string][]string, len(roles))
+	for name := range roles {
+		perms, err := expand(roles, name, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		result[name] = perms
+	}
+	return result, nil
+}
+
+// expand 递归展开角色的权限，visiting用于检测循环继承
+func expand(roles map[string]Role, name string, visiting map[string]bool) ([]string, error) {
+	if visiting[name] {
+		return nil, fmt.Errorf("rbac: role %s has circular inheritance", name)
+	}
+	role, ok := roles[name]
+	if !ok {
+		return nil, fmt.Errorf("rbac: role %s not defined", name)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	perms := append([]string{}, role.Permissions...)
+	for _, parent := range role.Inherits {
+		inherited, err := expand(roles, parent, visiting)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, inherited...)
+	}
+	return perms, nil
+}
+
+// Match 判断权限模式pattern是否覆盖permission
+// 模式按:分段比较，*匹配任意一段；末尾的*同时匹配其后的所有段，如order:*可匹配order:item:read
+func Match(pattern, permission string) bool {
+	if pattern == "*" || pattern == permission {
+		return true
+	}
+	ps := strings.Split(pattern, ":")
+	ss := strings.Split(permission, ":")
+	for i, seg := range ps {
+		if i >= len(ss) {
+			return false
+		}
+		if seg == "*" {
+			if i == len(ps)-1 {
+				return true
+			}
+			continue
+		}
+		if seg != ss[i] {
+			return false
+		}
+	}
+	return len(ps) == len(ss)
+}
@@ -2,36 +2,255 @@ package redis
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/go-redis/redis/v8"
 )
 
 var ctx = context.TODO()
 
-const unlock = "if redis.call('get', KEYS[1]) == ARGV[1] then redis.call('del', KEYS[1]); return 1; end return 0;"
+// ErrLockTimeout 等待加锁超时
+var ErrLockTimeout = errors.New("redis: lock wait timeout")
 
-// Lock 分布式锁加锁
-func (cli *Client) Lock(key string, expiration time.Duration) bool {
-	cli.mutex.Lock()
-	defer cli.mutex.Unlock()
+// NotOwnerError 锁已过期或被其他持有者占用，当前持有者无法解锁或续期
+type NotOwnerError struct {
+	Key string
+}
 
-	// 已经被其他goroutine加锁了
-	if cli.Get(ctx, key).Val() != "" {
-		return false
-	}
+func (e *NotOwnerError) Error() string {
+	return fmt.Sprintf("redis: lock %s is not owned by the caller", e.Key)
+}
+
+// 加锁失败后重试的退避时长，每次翻倍并加上随机抖动
+const (
+	lockMinBackoff = 10 * time.Millisecond
+	lockMaxBackoff = 200 * time.Millisecond
+)
+
+var (
+	// KEYS[1] 锁 ARGV[1] 持有者 ARGV[2] 有效期毫秒
+	extendLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 0`)
+	// KEYS[1] 锁 ARGV[1] 持有者
+	releaseLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0`)
+)
+
+// Locker 分布式锁
+// 所有锁类型在redis中的key都经过Client.WrapKey加上命名空间，开启AutoNamespace时由客户端自动添加，
+// 传入的key和Lock.Key()均为不带命名空间的原key
+type Locker interface {
+	// TryLock 加锁，锁被占用时按退避重试直到waitTimeout，超时返回ErrLockTimeout，waitTimeout为0时只尝试一次
+	// 加锁成功后由看门狗定时续期，直到Unlock
+	TryLock(ctx context.Context, key string, ttl, waitTimeout time.Duration) (*Lock, error)
+}
+
+// lockStore 锁在redis中的存取方式，返回false表示锁被占用或已不属于token
+type lockStore interface {
+	acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key, token string) (bool, error)
+}
+
+// validityStore 锁的有效期短于ttl的lockStore，如Redlock需扣除时钟漂移
+type validityStore interface {
+	validity(ttl time.Duration) time.Duration
+}
 
-	// 尝试设置Redis锁
-	ok, err := cli.SetNX(ctx, key, "1", expiration).Result()
+// waitCanceler 加锁时会登记排队的lockStore，放弃等待时需要取消登记
+type waitCanceler interface {
+	cancelWait(ctx context.Context, key, token string) error
+}
+
+// mutexStore 互斥锁，值为持有者的唯一标识
+type mutexStore struct {
+	cli *Client
+}
+
+func (s mutexStore) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.cli.SetNX(ctx, s.cli.WrapKey(key), token, ttl).Result()
+}
+
+func (s mutexStore) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLock.Run(ctx, s.cli, []string{s.cli.WrapKey(key)}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s mutexStore) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := releaseLock.Run(ctx, s.cli, []string{s.cli.WrapKey(key)}, token).Int()
+	return n == 1, err
+}
+
+// TryLock 获取互斥锁，锁中保存本次加锁的唯一标识，只有持有者能解锁
+func (cli *Client) TryLock(ctx context.Context, key string, ttl, waitTimeout time.Duration) (*Lock, error) {
+	token, err := newLockToken()
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return ok
+	return obtainLock(ctx, mutexStore{cli: cli}, key, token, ttl, waitTimeout)
 }
 
-// Unlock 分布式锁解锁
-func (cli *Client) Unlock(key string) bool {
-	i, err := cli.Eval(ctx, unlock, []string{key}, "1").Int()
+// Lock 已获取的锁，持有期间看门狗每ttl/3续期一次
+type Lock struct {
+	store lockStore
+	key   string
+	token string
+	ttl   time.Duration
+	// validity 每次加锁或续期后锁的有效期，一般等于ttl
+	validity time.Duration
+
+	lost      int32 // 续期失败，锁已丢失
+	renewedAt int64 // 最后一次加锁或续期成功前发出请求的纳秒时间，锁至少有效到renewedAt+validity
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// obtainLock 按退避重试加锁直到成功或超时，成功后启动看门狗；waitTimeout小于0时一直等待直到ctx结束
+func obtainLock(ctx context.Context, store lockStore, key, token string, ttl, waitTimeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(waitTimeout)
+	backoff := lockMinBackoff
+	var acquiredAt time.Time
+	for {
+		acquiredAt = time.Now()
+		ok, err := store.acquire(ctx, key, token, ttl)
+		if err != nil {
+			giveUp(store, key, token)
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		wait := backoff/2 + time.Duration(mrand.Int63n(int64(backoff)))
+		if waitTimeout >= 0 {
+			if remain := time.Until(deadline); remain <= 0 {
+				giveUp(store, key, token)
+				return nil, ErrLockTimeout
+			} else if wait > remain {
+				wait = remain
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			giveUp(store, key, token)
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > lockMaxBackoff {
+			backoff = lockMaxBackoff
+		}
+	}
+
+	l := &Lock{store: store, key: key, token: token, ttl: ttl, validity: ttl, renewedAt: acquiredAt.UnixNano(), stop: make(chan struct{}), done: make(chan struct{})}
+	if v, ok := store.(validityStore); ok {
+		l.validity = v.validity(ttl)
+	}
+	go l.watchdog()
+	return l, nil
+}
+
+// giveUp 放弃等待，取消排队登记；此时调用方的ctx可能已结束，使用单独的超时
+func giveUp(store lockStore, key, token string) {
+	if c, ok := store.(waitCanceler); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = c.cancelWait(ctx, key, token)
+	}
+}
+
+// Key 锁的key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token 持有者的唯一标识，可重入锁为ctx中的持有者标识
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Lost 锁已过期或被他人占用，此时不应再认为持有该锁
+// 调用时按最后一次续期成功的时间判断，续期请求卡住时不必等看门狗超时也能发现锁已过期；Redlock的有效期扣除时钟漂移
+func (l *Lock) Lost() bool {
+	return atomic.LoadInt32(&l.lost) == 1 || time.Since(time.Unix(0, atomic.LoadInt64(&l.renewedAt))) >= l.validity
+}
+
+// Unlock 停止续期并解锁，锁已不属于当前持有者时返回*NotOwnerError
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	ok, err := l.store.release(ctx, l.key, l.token)
 	if err != nil {
-		return false
+		return err
+	}
+	if !ok {
+		return &NotOwnerError{Key: l.key}
 	}
-	return i > 0
+	return nil
+}
+
+// watchdog 定时续期；锁不再属于自己，或连续续期出错超过ttl时标记为丢失并退出
+func (l *Lock) watchdog() {
+	defer close(l.done)
+	interval := l.ttl / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			ok, err := l.store.extend(ctx, l.key, l.token, l.ttl)
+			cancel()
+			if err == nil && ok {
+				atomic.StoreInt64(&l.renewedAt, now.UnixNano())
+				continue
+			}
+			if err == nil || l.Lost() {
+				atomic.StoreInt32(&l.lost, 1)
+				return
+			}
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Lock 分布式锁加锁，值为当前客户端的唯一标识，不会续期
+//
+// Deprecated: 同一客户端的不同调用方可以互相解锁，请使用TryLock
+func (cli *Client) Lock(key string, expiration time.Duration) bool {
+	ok, err := mutexStore{cli: cli}.acquire(ctx, key, cli.token, expiration)
+	return err == nil && ok
+}
+
+// Unlock 分布式锁解锁，只能解除当前客户端加的锁
+//
+// Deprecated: 请使用TryLock返回的Lock.Unlock
+func (cli *Client) Unlock(key string) bool {
+	ok, err := mutexStore{cli: cli}.release(ctx, key, cli.token)
+	return err == nil && ok
 }
@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestClient(t *testing.T, mr *miniredis.Miniredis, autoNamespace bool) *Client {
+	cli := newClient(Conf{Addrs: []string{mr.Addr()}, Namespace: "ns", AutoNamespace: autoNamespace})
+	t.Cleanup(cli.Destroy)
+	return cli
+}
+
+// TestLockNamespace 所有锁类型的key都带命名空间，手动和自动添加的结果一致
+func TestLockNamespace(t *testing.T) {
+	for _, auto := range []bool{false, true} {
+		mr := miniredis.RunT(t)
+		cli := newTestClient(t, mr, auto)
+		ctx := context.Background()
+
+		lock, err := cli.TryLock(ctx, "mutex", time.Second, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reentrant, err := cli.TryLockReentrant(ctx, "reentrant", time.Second, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw, err := cli.NewRWMutex("rw").TryLock(ctx, time.Second, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cli.Lock("legacy", time.Second) {
+			t.Fatal("legacy Lock failed")
+		}
+		for _, key := range []string{"ns:mutex", "ns:reentrant", "ns:{rw}:write", "ns:legacy"} {
+			if !mr.Exists(key) {
+				t.Errorf("auto=%v: want key %s, got %v", auto, key, mr.Keys())
+			}
+		}
+
+		for _, l := range []*Lock{lock, reentrant, rw} {
+			if err := l.Unlock(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !cli.Unlock("legacy") {
+			t.Fatal("legacy Unlock failed")
+		}
+		if keys := mr.Keys(); len(keys) != 0 {
+			t.Errorf("auto=%v: keys left after unlock: %v", auto, keys)
+		}
+	}
+}
+
+// TestRWMutexWriterGiveUp 写者等待超时后清除等待标记，读者不必等标记过期
+func TestRWMutexWriterGiveUp(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cli := newTestClient(t, mr, false)
+	ctx := context.Background()
+	m := cli.NewRWMutex("rw")
+
+	reader, err := m.TryRLock(ctx, time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wait := range []time.Duration{0, 30 * time.Millisecond} {
+		if _, err := m.TryLock(ctx, time.Minute, wait); err != ErrLockTimeout {
+			t.Fatalf("want ErrLockTimeout, got %v", err)
+		}
+		if mr.Exists("ns:{rw}:wait") {
+			t.Fatal("wait marker left after the writer gave up")
+		}
+		other, err := m.TryRLock(ctx, time.Minute, 0)
+		if err != nil {
+			t.Fatalf("reader blocked after the writer gave up: %v", err)
+		}
+		_ = other.Unlock(ctx)
+	}
+	_ = reader.Unlock(ctx)
+}
+
+func TestRedlockValidity(t *testing.T) {
+	var confs []Conf
+	for i := 0; i < 3; i++ {
+		confs = append(confs, Conf{Addrs: []string{miniredis.RunT(t).Addr()}, Namespace: "ns"})
+	}
+	r, err := NewRedlock(confs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Destroy()
+
+	ctx := context.Background()
+	lock, err := r.TryLock(ctx, "redlock", time.Second, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock(ctx)
+	if want := time.Second - 12*time.Millisecond; lock.validity != want {
+		t.Fatalf("validity %s, want %s", lock.validity, want)
+	}
+	if lock.Lost() {
+		t.Fatal("lock lost right after acquire")
+	}
+}
@@ -0,0 +1,258 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// namespaceHook 在命令发送前给所有key加上命名空间前缀，返回key的命令在收到结果后去掉前缀
+// 单个命令、Do、脚本(EVAL/EVALSHA的KEYS)、pipeline和事务都会经过该hook
+type namespaceHook struct {
+	prefix string
+}
+
+func (h namespaceHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.wrap(cmd)
+	return ctx, nil
+}
+
+func (h namespaceHook) AfterProcess(_ context.Context, cmd redis.Cmder) error {
+	h.unwrap(cmd)
+	return nil
+}
+
+func (h namespaceHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	for _, cmd := range cmds {
+		h.wrap(cmd)
+	}
+	return ctx, nil
+}
+
+func (h namespaceHook) AfterProcessPipeline(_ context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		h.unwrap(cmd)
+	}
+	return nil
+}
+
+// wrap 直接修改命令的参数，给key加上前缀
+func (h namespaceHook) wrap(cmd redis.Cmder) {
+	args := cmd.Args()
+	for _, i := range keyPositions(args) {
+		if i < len(args) {
+			args[i] = h.prefix + argString(args[i])
+		}
+	}
+}
+
+// unwrap 去掉结果中key的前缀：KEYS、SCAN的结果(不属于命名空间的key会被过滤)，BLPOP/BRPOP、BZPOPMIN/BZPOPMAX返回的key
+func (h namespaceHook) unwrap(cmd redis.Cmder) {
+	if cmd.Err() != nil {
+		return
+	}
+	switch c := cmd.(type) {
+	case *redis.ScanCmd:
+		if c.Name() == "scan" {
+			page, cursor := c.Val()
+			c.SetVal(h.trimAll(page), cursor)
+		}
+	case *redis.StringSliceCmd:
+		switch c.Name() {
+		case "keys":
+			c.SetVal(h.trimAll(c.Val()))
+		case "blpop", "brpop":
+			if val := c.Val(); len(val) > 0 {
+				val[0] = strings.TrimPrefix(val[0], h.prefix)
+			}
+		}
+	case *redis.ZWithKeyCmd:
+		if val := c.Val(); val != nil {
+			val.Key = strings.TrimPrefix(val.Key, h.prefix)
+		}
+	}
+}
+
+func (h namespaceHook) trimAll(keys []string) []string {
+	trimmed := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, h.prefix) {
+			trimmed = append(trimmed, key[len(h.prefix):])
+		}
+	}
+	return trimmed
+}
+
+// noKeyCommands 不包含key的命令
+var noKeyCommands = map[string]bool{
+	"ping": true, "echo": true, "auth": true, "hello": true, "select": true, "quit": true, "swapdb": true,
+	"info": true, "dbsize": true, "time": true, "lastsave": true, "save": true, "bgsave": true, "bgrewriteaof": true,
+	"flushdb": true, "flushall": true, "randomkey": true, "shutdown": true, "role": true, "wait": true,
+	"multi": true, "exec": true, "discard": true, "unwatch": true, "readonly": true, "readwrite": true,
+	"client": true, "config": true, "cluster": true, "command": true, "script": true, "slowlog": true,
+	"debug": true, "monitor": true, "sync": true, "psync": true, "slaveof": true, "replicaof": true,
+	"acl": true, "latency": true, "module": true, "lolwut": true, "failover": true,
+	"publish": true, "pubsub": true, "subscribe": true, "psubscribe": true, "unsubscribe": true, "punsubscribe": true,
+}
+
+// keyPositions 计算命令参数中key所在的下标
+func keyPositions(args []interface{}) []int {
+	if len(args) < 2 {
+		return nil
+	}
+	name := strings.ToLower(argString(args[0]))
+	if noKeyCommands[name] {
+		return nil
+	}
+	switch name {
+	case "keys":
+		return []int{1}
+	case "scan":
+		// SCAN cursor MATCH pattern，没有MATCH时会遍历所有key，结果中只保留当前命名空间的key
+		for i := 2; i+1 < len(args); i++ {
+			if strings.EqualFold(argString(args[i]), "match") {
+				return []int{i + 1}
+			}
+		}
+		return nil
+	case "del", "unlink", "exists", "touch", "mget", "watch", "pfcount", "pfmerge",
+		"sinter", "sunion", "sdiff", "sinterstore", "sunionstore", "sdiffstore":
+		return span(1, len(args))
+	case "mset", "msetnx":
+		var pos []int
+		for i := 1; i < len(args); i += 2 {
+			pos = append(pos, i)
+		}
+		return pos
+	case "rename", "renamenx", "rpoplpush", "smove", "lmove", "blmove", "brpoplpush", "copy", "geosearchstore",
+		"zrangestore", "lcs":
+		return []int{1, 2}
+	case "blpop", "brpop", "bzpopmin", "bzpopmax":
+		return span(1, len(args)-1)
+	case "bitop":
+		return span(2, len(args))
+	case "object", "memory", "xgroup", "xinfo":
+		// 子命令在前，key在子命令之后；HELP等没有key的子命令参数不足，不会返回下标
+		if len(args) < 3 {
+			return nil
+		}
+		return []int{2}
+	case "eval", "evalsha", "eval_ro", "evalsha_ro", "fcall", "fcall_ro":
+		return numKeys(args, 2, 3)
+	case "zunion", "zinter", "zdiff", "sintercard", "zintercard", "lmpop", "zmpop":
+		return numKeys(args, 1, 2)
+	case "zunionstore", "zinterstore", "zdiffstore":
+		return append([]int{1}, numKeys(args, 2, 3)...)
+	case "blmpop", "bzmpop":
+		return numKeys(args, 2, 3)
+	case "sort", "sort_ro":
+		return sortKeys(args)
+	case "georadius":
+		// GEORADIUS key longitude latitude radius unit [选项]，选项中STORE、STOREDIST后为目标key
+		return append([]int{1}, optionKeys(args, 6, "store", "storedist")...)
+	case "georadiusbymember":
+		// GEORADIUSBYMEMBER key member radius unit [选项]
+		return append([]int{1}, optionKeys(args, 5, "store", "storedist")...)
+	case "migrate":
+		return migrateKeys(args)
+	case "xread", "xreadgroup":
+		for i := 1; i < len(args); i++ {
+			if strings.EqualFold(argString(args[i]), "streams") {
+				return span(i+1, i+1+(len(args)-i-1)/2)
+			}
+		}
+		return nil
+	default:
+		return []int{1}
+	}
+}
+
+// sortKeys SORT key [BY pattern] [LIMIT offset count] [GET pattern ...] [ASC|DESC] [ALPHA] [STORE destination]
+// BY、GET的pattern也是key，BY nosort和GET #除外
+func sortKeys(args []interface{}) []int {
+	pos := []int{1}
+	for i := 2; i+1 < len(args); i++ {
+		switch opt, val := strings.ToLower(argString(args[i])), argString(args[i+1]); {
+		case opt == "limit":
+			i += 2
+		case opt == "by" && !strings.EqualFold(val, "nosort"), opt == "get" && val != "#", opt == "store":
+			pos = append(pos, i+1)
+			i++
+		case opt == "by", opt == "get":
+			i++
+		}
+	}
+	return pos
+}
+
+// optionKeys 从下标from开始的选项中，names后的参数为key
+func optionKeys(args []interface{}, from int, names ...string) []int {
+	var pos []int
+	for i := from; i+1 < len(args); i++ {
+		for _, name := range names {
+			if strings.EqualFold(argString(args[i]), name) {
+				pos = append(pos, i+1)
+				i++
+				break
+			}
+		}
+	}
+	return pos
+}
+
+// migrateKeys MIGRATE host port key|"" db timeout [COPY] [REPLACE] [AUTH password] [AUTH2 username password] [KEYS key ...]
+// key为空字符串时迁移KEYS后的多个key
+func migrateKeys(args []interface{}) []int {
+	if len(args) < 4 {
+		return nil
+	}
+	if argString(args[3]) != "" {
+		return []int{3}
+	}
+	for i := 6; i < len(args); i++ {
+		switch strings.ToLower(argString(args[i])) {
+		case "auth":
+			i++
+		case "auth2":
+			i += 2
+		case "keys":
+			return span(i+1, len(args))
+		}
+	}
+	return nil
+}
+
+// numKeys 参数countAt为key的个数，key从下标first开始
+func numKeys(args []interface{}, countAt, first int) []int {
+	if countAt >= len(args) {
+		return nil
+	}
+	n, err := strconv.Atoi(argString(args[countAt]))
+	if err != nil || first+n > len(args) {
+		return nil
+	}
+	return span(first, first+n)
+}
+
+// span 下标[from, to)
+func span(from, to int) []int {
+	pos := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		pos = append(pos, i)
+	}
+	return pos
+}
+
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
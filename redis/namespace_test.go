@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyPositions(t *testing.T) {
+	cases := []struct {
+		name string
+		args []interface{}
+		want []int
+	}{
+		{"get", []interface{}{"get", "k"}, []int{1}},
+		{"no key", []interface{}{"ping", "hi"}, nil},
+		{"del", []interface{}{"del", "a", "b"}, []int{1, 2}},
+		{"mset", []interface{}{"mset", "a", 1, "b", 2}, []int{1, 3}},
+		{"scan match", []interface{}{"scan", 0, "match", "user:*", "count", 10}, []int{3}},
+		{"scan all", []interface{}{"scan", 0}, nil},
+		{"eval", []interface{}{"eval", "return 1", 2, "a", "b", "arg"}, []int{3, 4}},
+		{"zunionstore", []interface{}{"zunionstore", "dst", 2, "a", "b", "weights", 1, 2}, []int{1, 3, 4}},
+		{"xread", []interface{}{"xread", "count", 1, "streams", "a", "b", "0", "0"}, []int{4, 5}},
+		{"blpop", []interface{}{"blpop", "a", "b", 0}, []int{1, 2}},
+		{"object", []interface{}{"object", "encoding", "k"}, []int{2}},
+
+		{"xgroup create", []interface{}{"xgroup", "create", "stream", "group", "$", "mkstream"}, []int{2}},
+		{"xgroup help", []interface{}{"xgroup", "help"}, nil},
+		{"xinfo stream", []interface{}{"xinfo", "stream", "stream", "full"}, []int{2}},
+		{"xinfo consumers", []interface{}{"xinfo", "consumers", "stream", "group"}, []int{2}},
+		{"xinfo help", []interface{}{"xinfo", "help"}, nil},
+
+		{"sort", []interface{}{"sort", "list", "desc", "alpha"}, []int{1}},
+		{"sort store", []interface{}{"sort", "list", "limit", 0, 10, "store", "dst"}, []int{1, 6}},
+		{"sort by get", []interface{}{"sort", "list", "by", "w_*", "get", "#", "get", "o_*", "store", "dst"}, []int{1, 3, 7, 9}},
+		{"sort by nosort", []interface{}{"sort", "list", "by", "nosort", "get", "o_*"}, []int{1, 5}},
+		{"sort limit keyword values", []interface{}{"sort", "list", "limit", "store", "get"}, []int{1}},
+
+		{"georadius", []interface{}{"georadius", "geo", 1.0, 2.0, 5, "km", "withdist"}, []int{1}},
+		{"georadius store", []interface{}{"georadius", "geo", 1.0, 2.0, 5, "km", "count", 10, "store", "dst"}, []int{1, 9}},
+		{"georadius storedist", []interface{}{"georadius", "geo", 1.0, 2.0, 5, "km", "storedist", "dst"}, []int{1, 7}},
+		{"georadiusbymember store", []interface{}{"georadiusbymember", "geo", "store", 5, "km", "store", "dst"}, []int{1, 6}},
+		{"geosearchstore", []interface{}{"geosearchstore", "dst", "src", "frommember", "m", "byradius", 5, "km"}, []int{1, 2}},
+
+		{"zrangestore", []interface{}{"zrangestore", "dst", "src", 0, -1}, []int{1, 2}},
+
+		{"migrate", []interface{}{"migrate", "host", 6379, "k", 0, 1000}, []int{3}},
+		{"migrate keys", []interface{}{"migrate", "host", 6379, "", 0, 1000, "copy", "auth", "keys", "keys", "a", "b"}, []int{10, 11}},
+		{"migrate auth2", []interface{}{"migrate", "host", 6379, "", 0, 1000, "auth2", "keys", "keys", "keys", "a"}, []int{10}},
+	}
+	for _, c := range cases {
+		if got := keyPositions(c.args); !reflect.DeepEqual(got, c.want) && !(len(got) == 0 && len(c.want) == 0) {
+			t.Errorf("%s: keyPositions(%v) = %v, want %v", c.name, c.args, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateAlgorithm 限流算法
+type RateAlgorithm string
+
+const (
+	TokenBucket RateAlgorithm = "tokenBucket" // 令牌桶：按固定速率补充令牌，允许突发
+	SlidingLog  RateAlgorithm = "slidingLog"  // 滑动窗口日志：记录窗口内每次请求的时间，最精确但占用内存与请求数成正比
+	GCRA        RateAlgorithm = "gcra"        // 通用信元速率算法：只保存一个时间戳，效果与令牌桶相同，开销最小
+)
+
+// RateRule 限流规则：Period秒内最多Limit次请求
+type RateRule struct {
+	Algorithm RateAlgorithm `yaml:"algorithm"` // 限流算法，默认GCRA
+	Limit     int           `yaml:"limit"`     // 周期内允许的请求数
+	Period    int           `yaml:"period"`    // 周期，单位：秒，默认1
+	Burst     int           `yaml:"burst"`     // 令牌桶和GCRA允许的突发请求数，默认等于Limit
+}
+
+// RateResult 限流结果
+type RateResult struct {
+	Allowed    bool          // 是否允许本次请求
+	Limit      int           // 周期内允许的请求数
+	Remaining  int           // 剩余可用的请求数
+	RetryAfter time.Duration // 被限流时，多久之后可以重试
+	ResetAfter time.Duration // 多久之后恢复到满额
+}
+
+// 以下脚本的时间单位均为微秒，返回{是否允许, 剩余次数, 重试等待, 恢复满额等待}
+var (
+	// KEYS[1] 令牌桶hash ARGV[1] 容量 ARGV[2] 补充一个令牌的微秒数 ARGV[3] 当前微秒
+	tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens') or capacity)
+local ts = tonumber(redis.call('HGET', KEYS[1], 'ts') or now)
+if now > ts then tokens = math.min(capacity, tokens + (now - ts) / interval) end
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * interval)
+end
+local reset = math.ceil((capacity - tokens) * interval)
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', math.max(now, ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(reset / 1000) + 1000)
+return {allowed, math.floor(tokens), retry, reset}`)
+	// KEYS[1] 请求日志zset ARGV[1] 上限 ARGV[2] 窗口微秒 ARGV[3] 当前微秒 ARGV[4] 唯一成员
+	slidingLogScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+local retry = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+else
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	retry = tonumber(oldest[2]) + window - now
+end
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+local reset = 0
+if newest[2] then reset = tonumber(newest[2]) + window - now end
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+return {allowed, limit - count, retry, reset}`)
+	// KEYS[1] 理论到达时间 ARGV[1] 请求间隔微秒 ARGV[2] 突发容忍微秒 ARGV[3] 当前微秒
+	gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local newTat = tat + interval
+local allowAt = newTat - tolerance
+if now < allowAt then
+	return {0, 0, allowAt - now, tat - now}
+end
+redis.call('SET', KEYS[1], newTat, 'PX', math.ceil((newTat - now) / 1000) + 1)
+return {1, math.floor((tolerance - (newTat - now)) / interval), 0, newTat - now}`)
+)
+
+// RateLimiter 基于redis的分布式限流器，每种算法都是一个原子执行的Lua脚本
+// 时间使用客户端时钟，各实例间的时钟偏差会影响限流精度
+type RateLimiter struct {
+	cli       *Client
+	keyPrefix string
+}
+
+// NewRateLimiter 创建限流器，keyPrefix为空时使用ratelimit
+func NewRateLimiter(cli *Client, keyPrefix string) *RateLimiter {
+	if keyPrefix == "" {
+		keyPrefix = "ratelimit"
+	}
+	return &RateLimiter{cli: cli, keyPrefix: keyPrefix}
+}
+
+// Allow 按规则对key计数一次请求，key通常为用户ID、IP或接口路径
+func (r *RateLimiter) Allow(ctx context.Context, key string, rule RateRule) (*RateResult, error) {
+	if rule.Algorithm == "" {
+		rule.Algorithm = GCRA
+	}
+	if rule.Period <= 0 {
+		rule.Period = 1
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Limit
+	}
+	if rule.Limit <= 0 {
+		return nil, fmt.Errorf("redis: rate limit must be positive")
+	}
+
+	period := int64(rule.Period) * 1e6
+	now := time.Now().UnixNano() / 1e3
+	keys := []string{r.cli.WrapKey(fmt.Sprintf("%s:%s:%d/%d:%s", r.keyPrefix, rule.Algorithm, rule.Limit, rule.Period, key))}
+
+	var cmd *redis.Cmd
+	switch rule.Algorithm {
+	case TokenBucket:
+		cmd = tokenBucketScript.Run(ctx, r.cli, keys, rule.Burst, period/int64(rule.Limit), now)
+	case SlidingLog:
+		member, err := newLockToken()
+		if err != nil {
+			return nil, err
+		}
+		cmd = slidingLogScript.Run(ctx, r.cli, keys, rule.Limit, period, now, member)
+	case GCRA:
+		interval := period / int64(rule.Limit)
+		cmd = gcraScript.Run(ctx, r.cli, keys, interval, interval*int64(rule.Burst), now)
+	default:
+		return nil, fmt.Errorf("redis: unknown rate limit algorithm %s", rule.Algorithm)
+	}
+
+	vals, err := cmd.Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	limit := rule.Limit
+	if rule.Algorithm != SlidingLog {
+		limit = rule.Burst
+	}
+	return &RateResult{
+		Allowed:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Microsecond,
+		ResetAfter: time.Duration(vals[3]) * time.Microsecond,
+	}, nil
+}
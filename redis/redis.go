@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -21,6 +20,12 @@ type Conf struct {
 	PoolSize    int      `yaml:"poolSize"`
 	MaxIdleConn int      `yaml:"maxIdleConn"`
 	Timeout     int      `yaml:"timeout"`
+
+	// AutoNamespace 为true时所有命令的key自动加上命名空间前缀，此时WrapKey原样返回，避免重复添加
+	AutoNamespace bool `yaml:"autoNamespace"`
+	// HashTag 集群模式下自动添加的前缀使用{namespace}:，同一命名空间的key落在同一个slot，多key命令和脚本不会跨slot，
+	// 但所有key都集中在一个节点上，只适合数据量不大的命名空间
+	HashTag bool `yaml:"hashTag"`
 }
 
 // RdbType Redis类型
@@ -36,14 +41,17 @@ const (
 type rdb interface {
 	redis.Cmdable
 	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	AddHook(hook redis.Hook)
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
 	Close() error
 }
 
 type Client struct {
-	mutex   sync.Mutex
 	rdbType RdbType
 	rdb
 	namespace string // key 的命名空间
+	autoWrap  bool   // 是否由hook自动添加命名空间
+	token     string // 客户端的唯一标识，用于旧的Lock/Unlock
 }
 
 // Destroy 销毁数据库客户端
@@ -51,13 +59,30 @@ func (cli *Client) Destroy() {
 	_ = cli.Close()
 }
 
-// WrapKey 使用配置的命名空间包装Key，返回一个包装过的key
+// Namespace 配置的命名空间
+func (cli *Client) Namespace() string {
+	return cli.namespace
+}
+
+// WrapKey 使用配置的命名空间包装Key，返回一个包装过的key，开启AutoNamespace时原样返回
 func (cli *Client) WrapKey(subKey string) string {
+	if cli.autoWrap {
+		return subKey
+	}
 	return fmt.Sprintf("%s:%s", cli.namespace, subKey)
 }
 
-// NewRedisClient 初始化Redis连接池
+// NewRedisClient 初始化Redis连接池，连接失败时退出进程
 func NewRedisClient(conf Conf) *Client {
+	rdb := newClient(conf)
+	if _, err := rdb.Ping(context.TODO()).Result(); err != nil {
+		log.Fatalf("redis ping fail: %v", err)
+	}
+	return rdb
+}
+
+// newClient 创建客户端，不检查连接，由调用方决定连接失败时如何处理
+func newClient(conf Conf) *Client {
 	timeout := 3 * time.Second
 	if conf.Timeout > 0 {
 		timeout = time.Duration(conf.Timeout) * time.Second
@@ -72,10 +97,16 @@ func NewRedisClient(conf Conf) *Client {
 		rdb = &Client{rdb: newSingleClient(conf, timeout), rdbType: conf.Type}
 	}
 
-	if _, err := rdb.Ping(context.TODO()).Result(); err != nil {
-		log.Fatalf("redis ping fail: %v", err)
-	}
 	rdb.namespace = conf.Namespace
+	if conf.AutoNamespace && conf.Namespace != "" {
+		prefix := conf.Namespace + ":"
+		if conf.HashTag && conf.Type == RdbCluster {
+			prefix = "{" + conf.Namespace + "}:"
+		}
+		rdb.AddHook(namespaceHook{prefix: prefix})
+		rdb.autoWrap = true
+	}
+	rdb.token, _ = newLockToken()
 	return rdb
 }
 
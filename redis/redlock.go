@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 时钟漂移系数，有效期扣除ttl*clockDriftFactor+2ms，参考Redlock算法
+const clockDriftFactor = 0.01
+
+var (
+	_ Locker = (*Client)(nil)
+	_ Locker = (*Redlock)(nil)
+)
+
+// Redlock 基于多个相互独立的redis master的分布式锁，在过半实例上加锁成功才算持有
+// 单个master故障切换不会导致锁被重复获取，适用于对互斥要求严格的场景
+type Redlock struct {
+	clients []*Client
+	quorum  int
+}
+
+// NewRedlock 连接多个独立的redis实例，实例之间不能是主从或同一集群，建议至少3个
+// 过半实例可以连接即可使用，少数实例宕机时不影响启动，恢复后自动参与加锁
+func NewRedlock(confs []Conf) (*Redlock, error) {
+	if len(confs) == 0 {
+		return nil, errors.New("redis: redlock requires at least one instance")
+	}
+	r := &Redlock{quorum: len(confs)/2 + 1}
+	for _, conf := range confs {
+		r.clients = append(r.clients, newClient(conf))
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		n    int
+		errs []error
+	)
+	for _, cli := range r.clients {
+		wg.Add(1)
+		go func(cli *Client) {
+			defer wg.Done()
+			err := cli.Ping(context.TODO()).Err()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			n++
+		}(cli)
+	}
+	wg.Wait()
+	if n < r.quorum {
+		r.Destroy()
+		return nil, fmt.Errorf("redis: only %d of %d redlock instances are reachable, need %d: %v", n, len(confs), r.quorum, errs)
+	}
+	return r, nil
+}
+
+// NewLocker 按配置创建分布式锁，只有一个实例时使用单实例锁，多个实例时使用Redlock，调用方无需关心
+// 单实例无法连接，或Redlock可连接的实例不过半时返回错误
+func NewLocker(confs []Conf) (Locker, error) {
+	if len(confs) == 1 {
+		cli := newClient(confs[0])
+		if err := cli.Ping(context.TODO()).Err(); err != nil {
+			cli.Destroy()
+			return nil, err
+		}
+		return cli, nil
+	}
+	r, err := NewRedlock(confs)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Destroy 关闭所有实例的连接
+func (r *Redlock) Destroy() {
+	for _, cli := range r.clients {
+		cli.Destroy()
+	}
+}
+
+// TryLock 在所有实例上加锁，过半成功且扣除耗时和时钟漂移后仍在有效期内才算成功，否则释放已加的锁后重试
+func (r *Redlock) TryLock(ctx context.Context, key string, ttl, waitTimeout time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	return obtainLock(ctx, r, key, token, ttl, waitTimeout)
+}
+
+func (r *Redlock) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	n, err := r.each(ctx, ttl, func(ctx context.Context, s mutexStore) (bool, error) {
+		return s.acquire(ctx, key, token, ttl)
+	})
+	if n >= r.quorum && r.validity(ttl)-time.Since(start) > 0 {
+		return true, nil
+	}
+	_, _ = r.release(ctx, key, token)
+	if n == 0 && err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// validity 扣除时钟漂移后的有效期，Lock.Lost按此判断，各实例上的锁可能比本地计时早到期
+func (r *Redlock) validity(ttl time.Duration) time.Duration {
+	return ttl - time.Duration(float64(ttl)*clockDriftFactor) - 2*time.Millisecond
+}
+
+func (r *Redlock) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := r.each(ctx, ttl, func(ctx context.Context, s mutexStore) (bool, error) {
+		return s.extend(ctx, key, token, ttl)
+	})
+	if n >= r.quorum {
+		return true, nil
+	}
+	return false, err
+}
+
+// release 在所有实例上解锁，包括加锁失败的实例，过半实例上解锁成功才算仍持有
+func (r *Redlock) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := r.each(ctx, 0, func(ctx context.Context, s mutexStore) (bool, error) {
+		return s.release(ctx, key, token)
+	})
+	if n >= r.quorum {
+		return true, nil
+	}
+	if n == 0 && err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// each 并发在所有实例上执行fn，返回成功的实例数及最后一个错误
+// ttl大于0时单个实例的超时为ttl/10，避免在故障实例上等待过久
+func (r *Redlock) each(ctx context.Context, ttl time.Duration, fn func(ctx context.Context, s mutexStore) (bool, error)) (int, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		n       int
+		lastErr error
+	)
+	for _, cli := range r.clients {
+		wg.Add(1)
+		go func(cli *Client) {
+			defer wg.Done()
+			c := ctx
+			if ttl > 0 {
+				var cancel context.CancelFunc
+				c, cancel = context.WithTimeout(ctx, ttl/10)
+				defer cancel()
+			}
+			ok, err := fn(c, mutexStore{cli: cli})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+			} else if ok {
+				n++
+			}
+		}(cli)
+	}
+	wg.Wait()
+	return n, lastErr
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type lockOwnerKey struct{}
+
+// WithLockOwner 在ctx中放入锁持有者标识，已存在时原样返回
+// 嵌套调用使用同一个ctx获取可重入锁时视为同一持有者
+func WithLockOwner(ctx context.Context) (context.Context, error) {
+	if _, ok := LockOwnerFromContext(ctx); ok {
+		return ctx, nil
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, lockOwnerKey{}, token), nil
+}
+
+// LockOwnerFromContext 获取ctx中的锁持有者标识
+func LockOwnerFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(lockOwnerKey{}).(string)
+	return token, ok
+}
+
+// 可重入锁保存在一个hash中，field为持有者，value为重入次数
+var (
+	// KEYS[1] 锁 ARGV[1] 持有者 ARGV[2] 有效期毫秒
+	acquireReentrant = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 or redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+	// KEYS[1] 锁 ARGV[1] 持有者 ARGV[2] 有效期毫秒
+	extendReentrant = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then return redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 0`)
+	// KEYS[1] 锁 ARGV[1] 持有者
+	releaseReentrant = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then return 0 end
+if redis.call('HINCRBY', KEYS[1], ARGV[1], -1) <= 0 then redis.call('DEL', KEYS[1]) end
+return 1`)
+)
+
+// reentrantStore 可重入锁
+type reentrantStore struct {
+	cli *Client
+}
+
+func (s reentrantStore) acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := acquireReentrant.Run(ctx, s.cli, []string{s.cli.WrapKey(key)}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s reentrantStore) extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	n, err := extendReentrant.Run(ctx, s.cli, []string{s.cli.WrapKey(key)}, token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s reentrantStore) release(ctx context.Context, key, token string) (bool, error) {
+	n, err := releaseReentrant.Run(ctx, s.cli, []string{s.cli.WrapKey(key)}, token).Int()
+	return n == 1, err
+}
+
+// TryLockReentrant 获取可重入锁，持有者标识取自ctx(见WithLockOwner)，ctx中没有时每次加锁都是不同的持有者
+// 同一持有者可以重复加锁，每次加锁返回的Lock都需要Unlock，重入次数减到0时释放
+func (cli *Client) TryLockReentrant(ctx context.Context, key string, ttl, waitTimeout time.Duration) (*Lock, error) {
+	token, ok := LockOwnerFromContext(ctx)
+	if !ok {
+		var err error
+		if token, err = newLockToken(); err != nil {
+			return nil, err
+		}
+	}
+	return obtainLock(ctx, reentrantStore{cli: cli}, key, token, ttl, waitTimeout)
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 读写锁使用三个key，用{}包住原key保证集群模式下落在同一个slot：
+// {key}:write 写锁，值为持有者
+// {key}:read  读锁，zset，member为持有者，score为到期毫秒，过期的读者在每次加锁时清理
+// {key}:wait  等待中的写者，存在时新的读者不能加锁，避免写者饥饿
+var (
+	// KEYS[1] 写锁 KEYS[2] 读锁 KEYS[3] 等待标记 ARGV[1] 持有者 ARGV[2] 有效期毫秒 ARGV[3] 当前毫秒
+	acquireRead = redis.NewScript(`
+local now = tonumber(ARGV[3])
+if redis.call('EXISTS', KEYS[1]) == 1 or redis.call('EXISTS', KEYS[3]) == 1 then return 0 end
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+redis.call('ZADD', KEYS[2], now + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[2]) < tonumber(ARGV[2]) then redis.call('PEXPIRE', KEYS[2], ARGV[2]) end
+return 1`)
+	// KEYS[1] 读锁 ARGV[1] 持有者 ARGV[2] 有效期毫秒 ARGV[3] 当前毫秒
+	extendRead = redis.NewScript(`
+local now = tonumber(ARGV[3])
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) < now then return 0 end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then redis.call('PEXPIRE', KEYS[1], ARGV[2]) end
+return 1`)
+	// KEYS[1] 读锁 ARGV[1] 持有者
+	releaseRead = redis.NewScript(`return redis.call('ZREM', KEYS[1], ARGV[1])`)
+	// KEYS[1] 写锁 KEYS[2] 读锁 KEYS[3] 等待标记 ARGV[1] 持有者 ARGV[2] 有效期毫秒 ARGV[3] 当前毫秒
+	acquireWrite = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then return 0 end
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[3])
+if redis.call('ZCARD', KEYS[2]) > 0 then
+	redis.call('SET', KEYS[3], ARGV[1], 'PX', ARGV[2])
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('DEL', KEYS[3])
+return 1`)
+	// KEYS[1] 等待标记 ARGV[1] 持有者，标记已被其他写者覆盖时不删除
+	cancelWrite = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) end
+return 0`)
+)
+
+// RWMutex 分布式读写锁，多个读者可以同时持有，写者独占
+// 读者的到期时间使用客户端时钟计算，各实例间的时钟偏差应远小于ttl
+type RWMutex struct {
+	cli *Client
+	key string
+}
+
+// NewRWMutex 创建key对应的读写锁
+func (cli *Client) NewRWMutex(key string) *RWMutex {
+	return &RWMutex{cli: cli, key: key}
+}
+
+// TryRLock 加读锁，有写者持有或等待时按退避重试直到waitTimeout
+func (m *RWMutex) TryRLock(ctx context.Context, ttl, waitTimeout time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	return obtainLock(ctx, readStore{m}, m.key, token, ttl, waitTimeout)
+}
+
+// TryLock 加写锁，有读者或写者持有时按退避重试直到waitTimeout，等待期间阻止新的读者加锁
+func (m *RWMutex) TryLock(ctx context.Context, ttl, waitTimeout time.Duration) (*Lock, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	return obtainLock(ctx, writeStore{m}, m.key, token, ttl, waitTimeout)
+}
+
+// keys 加上命名空间的三个key，命名空间在{}之外，不影响slot
+func (m *RWMutex) keys() []string {
+	prefix := "{" + m.key + "}:"
+	return []string{m.cli.WrapKey(prefix + "write"), m.cli.WrapKey(prefix + "read"), m.cli.WrapKey(prefix + "wait")}
+}
+
+type readStore struct {
+	m *RWMutex
+}
+
+func (s readStore) acquire(ctx context.Context, _, token string, ttl time.Duration) (bool, error) {
+	n, err := acquireRead.Run(ctx, s.m.cli, s.m.keys(), token, ttl.Milliseconds(), time.Now().UnixNano()/1e6).Int()
+	return n == 1, err
+}
+
+func (s readStore) extend(ctx context.Context, _, token string, ttl time.Duration) (bool, error) {
+	n, err := extendRead.Run(ctx, s.m.cli, s.m.keys()[1:2], token, ttl.Milliseconds(), time.Now().UnixNano()/1e6).Int()
+	return n == 1, err
+}
+
+func (s readStore) release(ctx context.Context, _, token string) (bool, error) {
+	n, err := releaseRead.Run(ctx, s.m.cli, s.m.keys()[1:2], token).Int()
+	return n == 1, err
+}
+
+type writeStore struct {
+	m *RWMutex
+}
+
+func (s writeStore) acquire(ctx context.Context, _, token string, ttl time.Duration) (bool, error) {
+	n, err := acquireWrite.Run(ctx, s.m.cli, s.m.keys(), token, ttl.Milliseconds(), time.Now().UnixNano()/1e6).Int()
+	return n == 1, err
+}
+
+func (s writeStore) extend(ctx context.Context, _, token string, ttl time.Duration) (bool, error) {
+	n, err := extendLock.Run(ctx, s.m.cli, s.m.keys()[:1], token, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s writeStore) release(ctx context.Context, _, token string) (bool, error) {
+	n, err := releaseLock.Run(ctx, s.m.cli, s.m.keys()[:1], token).Int()
+	return n == 1, err
+}
+
+// cancelWait 写者放弃等待时删除自己的等待标记，否则读者要等到标记过期才能加锁
+func (s writeStore) cancelWait(ctx context.Context, _, token string) error {
+	return cancelWrite.Run(ctx, s.m.cli, s.m.keys()[2:], token).Err()
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 排队者的存活时间，排队期间每次重试都会续期，进程崩溃后超过该时间的排队记录会被清理
+const semWaiterTTL = 2 * time.Second
+
+// 信号量使用四个key，用{}包住名称保证集群模式下落在同一个slot：
+// {name}:holders 持有者zset，score为到期毫秒，过期的持有者在每次操作时清理
+// {name}:queue   排队者zset，score为排队序号，按序号先后获得许可
+// {name}:alive   排队者zset，score为存活到期毫秒，用于清理崩溃的排队者
+// {name}:seq     排队序号计数器
+const semTouch = `
+local function touch(ms)
+	for i = 1, #KEYS do
+		if redis.call('PTTL', KEYS[i]) < ms then redis.call('PEXPIRE', KEYS[i], ms) end
+	end
+end
+`
+
+var (
+	// ARGV[1] 持有者 ARGV[2] 许可数 ARGV[3] 当前毫秒 ARGV[4] 持有毫秒 ARGV[5] 排队者存活毫秒
+	acquireSemaphore = redis.NewScript(semTouch + `
+local now = tonumber(ARGV[3])
+local ttl = tonumber(ARGV[4])
+local keep = math.max(ttl, tonumber(ARGV[5]))
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local dead = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now)
+for _, token in ipairs(dead) do redis.call('ZREM', KEYS[2], token) end
+redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now)
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	redis.call('ZADD', KEYS[1], now + ttl, ARGV[1])
+	touch(keep)
+	return 1
+end
+if not redis.call('ZSCORE', KEYS[2], ARGV[1]) then
+	redis.call('ZADD', KEYS[2], redis.call('INCR', KEYS[4]), ARGV[1])
+end
+local free = tonumber(ARGV[2]) - redis.call('ZCARD', KEYS[1])
+if redis.call('ZRANK', KEYS[2], ARGV[1]) < free then
+	redis.call('ZADD', KEYS[1], now + ttl, ARGV[1])
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('ZREM', KEYS[3], ARGV[1])
+	touch(keep)
+	return 1
+end
+redis.call('ZADD', KEYS[3], now + tonumber(ARGV[5]), ARGV[1])
+touch(keep)
+return 0`)
+	// ARGV[1] 持有者 ARGV[2] 当前毫秒 ARGV[3] 持有毫秒
+	extendSemaphore = redis.NewScript(semTouch + `
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not score or tonumber(score) < tonumber(ARGV[2]) then return 0 end
+redis.call('ZADD', KEYS[1], tonumber(ARGV[2]) + tonumber(ARGV[3]), ARGV[1])
+touch(tonumber(ARGV[3]))
+return 1`)
+	// KEYS[1] 持有者 ARGV[1] 持有者
+	releaseSemaphore = redis.NewScript(`return redis.call('ZREM', KEYS[1], ARGV[1])`)
+	// KEYS[1] 排队者 KEYS[2] 排队者存活 ARGV[1] 持有者
+	cancelSemaphore = redis.NewScript(`
+redis.call('ZREM', KEYS[1], ARGV[1])
+return redis.call('ZREM', KEYS[2], ARGV[1])`)
+)
+
+// Permit 从信号量获取到的许可，持有期间看门狗每ttl/3续期一次，进程崩溃后超过ttl自动归还
+type Permit struct {
+	lock *Lock
+}
+
+// Acquire 从名为name、总许可数为limit的分布式信号量获取一个许可，用于限制所有实例对某个资源的并发数
+// 没有空闲许可时按先来后到排队等待，直到ctx结束，等待超时请通过context.WithTimeout设置
+func (cli *Client) Acquire(ctx context.Context, name string, limit int, ttl time.Duration) (*Permit, error) {
+	if limit <= 0 {
+		return nil, errors.New("redis: semaphore limit must be positive")
+	}
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := obtainLock(ctx, semaphoreStore{cli: cli, limit: limit}, name, token, ttl, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &Permit{lock: lock}, nil
+}
+
+// Release 归还许可，许可已过期被回收时返回*NotOwnerError
+func (p *Permit) Release(ctx context.Context) error {
+	return p.lock.Unlock(ctx)
+}
+
+// Lost 看门狗续期时发现许可已过期被回收
+func (p *Permit) Lost() bool {
+	return p.lock.Lost()
+}
+
+// semaphoreStore 信号量，key为信号量名称
+type semaphoreStore struct {
+	cli   *Client
+	limit int
+}
+
+func (s semaphoreStore) acquire(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	n, err := acquireSemaphore.Run(ctx, s.cli, s.keys(name), token, s.limit, nowMillis(), ttl.Milliseconds(), semWaiterTTL.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s semaphoreStore) extend(ctx context.Context, name, token string, ttl time.Duration) (bool, error) {
+	n, err := extendSemaphore.Run(ctx, s.cli, s.keys(name), token, nowMillis(), ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s semaphoreStore) release(ctx context.Context, name, token string) (bool, error) {
+	n, err := releaseSemaphore.Run(ctx, s.cli, s.keys(name)[:1], token).Int()
+	return n == 1, err
+}
+
+func (s semaphoreStore) cancelWait(ctx context.Context, name, token string) error {
+	return cancelSemaphore.Run(ctx, s.cli, s.keys(name)[1:3], token).Err()
+}
+
+// keys 加上命名空间的四个key，命名空间在{}之外，不影响slot
+func (s semaphoreStore) keys(name string) []string {
+	prefix := "{" + name + "}:"
+	keys := []string{"holders", "queue", "alive", "seq"}
+	for i, key := range keys {
+		keys[i] = s.cli.WrapKey(prefix + key)
+	}
+	return keys
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / 1e6
+}
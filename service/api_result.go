@@ -40,6 +40,12 @@ func (res *ApiResult) Data(data interface{}) *ApiResult {
 	return res
 }
 
+// WithData 复制一份结果并设置data，用于给公共错误附带数据而不修改原变量
+func (res ApiResult) WithData(data interface{}) *ApiResult {
+	res.data = data
+	return &res
+}
+
 func (res ApiResult) MarshalJSON() ([]byte, error) {
 	builder := &strings.Builder{}
 	builder.WriteString("{\"code\":\"")
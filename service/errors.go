@@ -7,3 +7,39 @@ var (
 	ErrBadParamInput       = NewApiError("PUB_BAD_PARAM", "参数错误")               // 公共错误-参数错误
 	ErrHttpMethod          = NewApiError("PUB_HTTP_METHOD_ERR", "不支持的HTTP请求方法") // 公共错误-不支持的HTTP请求方法
 )
+
+var (
+	ErrTokenMissing = NewApiError("PUB_TOKEN_MISSING", "缺少访问令牌")  // 认证错误-请求未携带token
+	ErrTokenExpired = NewApiError("PUB_TOKEN_EXPIRED", "访问令牌已过期") // 认证错误-token已过期
+	ErrTokenInvalid = NewApiError("PUB_TOKEN_INVALID", "访问令牌无效")  // 认证错误-token签名错误或格式错误
+)
+
+var (
+	ErrForbidden = NewApiError("PUB_FORBIDDEN", "没有访问权限") // 授权错误-当前角色没有访问权限
+)
+
+var (
+	ErrTokenNotValidYet = NewApiError("PUB_TOKEN_NOT_VALID_YET", "访问令牌尚未生效") // 认证错误-token未到生效时间
+	ErrTokenSignature   = NewApiError("PUB_TOKEN_SIGNATURE", "访问令牌签名错误")     // 认证错误-token签名或签名算法不正确
+	ErrTokenAudience    = NewApiError("PUB_TOKEN_AUDIENCE", "访问令牌受众不匹配")     // 认证错误-token的颁发者或受众不匹配
+)
+
+var (
+	ErrSignMissing = NewApiError("PUB_SIGN_MISSING", "缺少签名信息")   // 签名错误-缺少AppKey、时间戳、nonce或签名
+	ErrSignExpired = NewApiError("PUB_SIGN_EXPIRED", "请求时间戳已过期") // 签名错误-时间戳超出允许的偏差
+	ErrSignReplay  = NewApiError("PUB_SIGN_REPLAY", "重复的请求")     // 签名错误-nonce已被使用
+	ErrSignInvalid = NewApiError("PUB_SIGN_INVALID", "签名错误")     // 签名错误-AppKey不存在或签名不匹配
+)
+
+var (
+	ErrSessionRevoked  = NewApiError("PUB_SESSION_REVOKED", "登录状态已失效，请重新登录") // 会话错误-会话已被踢下线或注销
+	ErrTooManySessions = NewApiError("PUB_SESSION_LIMIT", "登录设备数已达上限")       // 会话错误-超过最大在线设备数
+)
+
+var (
+	ErrLoginLocked = NewApiError("PUB_LOGIN_LOCKED", "登录失败次数过多，请稍后重试") // 登录错误-账号或IP已被锁定
+)
+
+var (
+	ErrRateLimited = NewApiError("PUB_RATE_LIMITED", "请求过于频繁，请稍后重试") // 限流错误-超过接口的访问频率限制
+)
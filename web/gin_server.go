@@ -61,7 +61,7 @@ func (instance *GinServer) RunAsync() {
 // 1、停止接收新请求，等待已有请求执行完毕；
 // 2、如果等待时间超过ShutdownTimeout设定的时间，则强制关闭HttpServer
 func (instance *GinServer) WaitInterrupt() {
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	<-quit
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/idworker"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/18689221165/lynn-toolkit/types"
+	"github.com/gin-gonic/gin"
+)
+
+// DecodeIdHandler 调试用，按conf的位分配解析路由参数paramName中的雪花ID，conf为空时使用默认布局
+// conf不合法时在注册路由时panic，不会在请求时才按错误的布局解析
+func DecodeIdHandler(conf idworker.Conf, paramName string) gin.HandlerFunc {
+	mustValidIdConf(conf)
+	return func(c *gin.Context) {
+		id, err := UIntParam(c, paramName)
+		if err != nil {
+			c.JSON(http.StatusOK, service.ErrBadParamInput)
+			return
+		}
+		info, _ := conf.Decompose(int64(id))
+		c.JSON(http.StatusOK, service.Succ(info))
+	}
+}
+
+// idRange ID范围，超过JS安全整数范围，序列化为字符串
+type idRange struct {
+	MinId int64 `json:"minId,string"` // 最小ID
+	MaxId int64 `json:"maxId,string"` // 最大ID
+}
+
+// IdRangeHandler 调试用，根据查询参数start、end(格式2006-01-02 15:04:05)计算时间段内的最小、最大ID
+// conf不合法时在注册路由时panic
+func IdRangeHandler(conf idworker.Conf) gin.HandlerFunc {
+	mustValidIdConf(conf)
+	return func(c *gin.Context) {
+		start, err := types.ParseTimeStr(types.TimeFormart, c.Query("start"))
+		if err != nil {
+			c.JSON(http.StatusOK, service.ErrBadParamInput)
+			return
+		}
+		end := start
+		if v := c.Query("end"); v != "" {
+			if end, err = types.ParseTimeStr(types.TimeFormart, v); err != nil {
+				c.JSON(http.StatusOK, service.ErrBadParamInput)
+				return
+			}
+		}
+		min, max, _ := conf.IdRange(time.Time(start), time.Time(end))
+		c.JSON(http.StatusOK, service.Succ(idRange{MinId: min, MaxId: max}))
+	}
+}
+
+func mustValidIdConf(conf idworker.Conf) {
+	if err := conf.Validate(); err != nil {
+		panic(err)
+	}
+}
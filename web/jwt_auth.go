@@ -0,0 +1,136 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/gojwt"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	claimsKey    = "jwtClaims"       // gin.Context中存放claims的key
+	bearerPrefix = "Bearer "         // Authorization头的Bearer前缀
+	defaultAuth  = "Authorization"   // 未配置Header时默认从该头读取token
+	renewHeader  = "X-Renewed-Token" // 未配置RenewHeader时续签token写入的响应头
+)
+
+// JWTAuth jwt认证中间件
+// 从请求中读取token并校验，校验通过后将claims放入gin.Context及context.Context；
+// skipPaths为白名单，可以是路由定义(如/user/:id)或实际的请求路径，命中时跳过校验；
+// 配置了RenewWindow时，临近过期的token会自动续签，新token写入响应头(及cookie)
+func JWTAuth(conf gojwt.Conf, skipPaths ...string) gin.HandlerFunc {
+	return JWTAuthWithSession(conf, nil, skipPaths...)
+}
+
+// JWTAuthWithSession 带会话校验的jwt认证中间件
+// 通过SessionRegistry.Login签发的token会校验其会话是否已被踢下线或注销，续签时同步更新会话过期时间
+func JWTAuthWithSession(conf gojwt.Conf, sessions *gojwt.SessionRegistry, skipPaths ...string) gin.HandlerFunc {
+	header := conf.Header
+	if header == "" {
+		header = defaultAuth
+	}
+	policy := conf.Policy()
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] || skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		token := trimBearer(GetJwtToken(c, header))
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, service.ErrTokenMissing)
+			return
+		}
+
+		claims, err := gojwt.ParseTokenWithPolicy(token, conf.Secret, policy)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gojwt.ErrorResult(err))
+			return
+		}
+
+		if sessions != nil {
+			if err = sessions.Validate(c.Request.Context(), claims); err == gojwt.ErrSessionRevoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, service.ErrSessionRevoked)
+				return
+			} else if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, service.ErrInternalServerError)
+				return
+			}
+		}
+
+		setClaims(c, claims)
+		if gojwt.NeedRenew(claims, conf) {
+			if renewed := renewToken(c, claims, conf); renewed != nil && sessions != nil && claims.Id != "" {
+				expiresAt := time.Now().Add(time.Duration(renewed.EffectiveTime) * time.Second)
+				_ = sessions.Touch(c.Request.Context(), claims.Subject, claims.Id, expiresAt)
+			}
+		}
+		c.Next()
+	}
+}
+
+// renewToken 续签token，已达到最长会话时间时不续签，让其自然过期
+func renewToken(c *gin.Context, claims *gojwt.RoleClaims, conf gojwt.Conf) *gojwt.JwtToken {
+	renewed, err := gojwt.RenewToken(claims, conf)
+	if err != nil {
+		return nil
+	}
+	header := conf.RenewHeader
+	if header == "" {
+		header = renewHeader
+	}
+	c.Header(header, renewed.Token)
+	if conf.RenewCookie != "" {
+		c.SetCookie(conf.RenewCookie, renewed.Token, int(renewed.EffectiveTime), "/", "", false, true)
+	}
+	return renewed
+}
+
+// setClaims 将claims同时放入gin.Context和request的context.Context
+func setClaims(c *gin.Context, claims *gojwt.RoleClaims) {
+	c.Set(claimsKey, claims)
+	c.Request = c.Request.WithContext(gojwt.NewContext(c.Request.Context(), claims))
+}
+
+// trimBearer 去掉token的Bearer前缀(不区分大小写)
+func trimBearer(token string) string {
+	token = strings.TrimSpace(token)
+	if len(token) >= len(bearerPrefix) && strings.EqualFold(token[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(token[len(bearerPrefix):])
+	}
+	return token
+}
+
+// CurrentClaims 获取JWTAuth中间件解析出的claims，未认证时返回nil
+func CurrentClaims(c *gin.Context) *gojwt.RoleClaims {
+	if v, ok := c.Get(claimsKey); ok {
+		if claims, ok := v.(*gojwt.RoleClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// CurrentUID 获取当前登录用户的ID
+func CurrentUID(c *gin.Context) string {
+	if claims := CurrentClaims(c); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// CurrentRole 获取当前登录用户的角色
+func CurrentRole(c *gin.Context) string {
+	if claims := CurrentClaims(c); claims != nil {
+		return claims.Role
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// LoginGuardConf 登录防暴力破解相关配置
+type LoginGuardConf struct {
+	Window           int    `yaml:"window"`           // 统计失败次数的滑动窗口，单位：秒，默认900
+	AccountThreshold int    `yaml:"accountThreshold"` // 窗口内同一账号允许的失败次数，默认5
+	IPThreshold      int    `yaml:"ipThreshold"`      // 窗口内同一IP允许的失败次数，默认20
+	BaseLockout      int    `yaml:"baseLockout"`      // 首次锁定时长，单位：秒，默认60，之后每次锁定时长翻倍
+	MaxLockout       int    `yaml:"maxLockout"`       // 最长锁定时长，单位：秒，默认3600
+	KeyPrefix        string `yaml:"keyPrefix"`        // redis中的key前缀，默认login:guard
+}
+
+// LockedError 账号或IP被锁定
+type LockedError struct {
+	RetryAfter time.Duration // 多久之后可以重试
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("login locked, retry after %s", e.RetryAfter)
+}
+
+// recordFailure 在滑动窗口内记录一次失败，达到阈值后按锁定次数递增锁定时长
+// KEYS[1] 失败记录 KEYS[2] 锁定标记 KEYS[3] 锁定次数
+// ARGV[1] 当前毫秒 ARGV[2] 窗口毫秒 ARGV[3] 阈值 ARGV[4] 首次锁定毫秒 ARGV[5] 最长锁定毫秒 ARGV[6] 唯一成员
+// 返回锁定的毫秒数，未锁定返回0
+var recordFailure = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+redis.call('ZADD', KEYS[1], now, ARGV[6])
+redis.call('PEXPIRE', KEYS[1], window)
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[3]) then
+	return 0
+end
+local level = redis.call('INCR', KEYS[3])
+local maxLock = tonumber(ARGV[5])
+redis.call('PEXPIRE', KEYS[3], maxLock * 2)
+local lock = tonumber(ARGV[4]) * 2 ^ (level - 1)
+if lock > maxLock then lock = maxLock end
+redis.call('SET', KEYS[2], level, 'PX', lock)
+redis.call('DEL', KEYS[1])
+return lock
+`)
+
+// LoginGuard 登录防暴力破解，按账号和IP分别统计失败次数，超过阈值后逐级延长锁定时间
+type LoginGuard struct {
+	conf LoginGuardConf
+	rdb  *redis.Client
+}
+
+func NewLoginGuard(conf LoginGuardConf, rdb *redis.Client) *LoginGuard {
+	if conf.Window <= 0 {
+		conf.Window = 900
+	}
+	if conf.AccountThreshold <= 0 {
+		conf.AccountThreshold = 5
+	}
+	if conf.IPThreshold <= 0 {
+		conf.IPThreshold = 20
+	}
+	if conf.BaseLockout <= 0 {
+		conf.BaseLockout = 60
+	}
+	if conf.MaxLockout <= 0 {
+		conf.MaxLockout = 3600
+	}
+	if conf.KeyPrefix == "" {
+		conf.KeyPrefix = "login:guard"
+	}
+	return &LoginGuard{conf: conf, rdb: rdb}
+}
+
+// Check 登录前检查账号和IP是否被锁定，被锁定时返回*LockedError
+func (g *LoginGuard) Check(ctx context.Context, account, ip string) error {
+	var retry time.Duration
+	for _, key := range []string{g.key("account", account, "lock"), g.key("ip", ip, "lock")} {
+		ttl, err := g.rdb.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl > retry {
+			retry = ttl
+		}
+	}
+	if retry > 0 {
+		return &LockedError{RetryAfter: retry}
+	}
+	return nil
+}
+
+// Fail 记录一次登录失败，若因此触发锁定则返回*LockedError
+func (g *LoginGuard) Fail(ctx context.Context, account, ip string) error {
+	now := time.Now().UnixNano() / 1e6
+	member := strconv.FormatInt(time.Now().UnixNano(), 10)
+	args := []interface{}{now, g.conf.Window * 1000, 0, g.conf.BaseLockout * 1000, g.conf.MaxLockout * 1000, member}
+
+	var retry int64
+	for _, target := range []struct {
+		kind, name string
+		threshold  int
+	}{{"account", account, g.conf.AccountThreshold}, {"ip", ip, g.conf.IPThreshold}} {
+		if target.name == "" {
+			continue
+		}
+		args[2] = target.threshold
+		keys := []string{g.key(target.kind, target.name, "fail"), g.key(target.kind, target.name, "lock"), g.key(target.kind, target.name, "level")}
+		lock, err := recordFailure.Run(ctx, g.rdb, keys, args...).Int64()
+		if err != nil {
+			return err
+		}
+		if lock > retry {
+			retry = lock
+		}
+	}
+	if retry > 0 {
+		return &LockedError{RetryAfter: time.Duration(retry) * time.Millisecond}
+	}
+	return nil
+}
+
+// Succeed 登录成功后清除账号的失败记录和锁定等级，IP的记录保留
+func (g *LoginGuard) Succeed(ctx context.Context, account string) error {
+	return g.rdb.Del(ctx, g.key("account", account, "fail"), g.key("account", account, "level")).Err()
+}
+
+// Unlock 管理员解锁账号
+func (g *LoginGuard) Unlock(ctx context.Context, account string) error {
+	return g.clear(ctx, "account", account)
+}
+
+// UnlockIP 管理员解锁IP
+func (g *LoginGuard) UnlockIP(ctx context.Context, ip string) error {
+	return g.clear(ctx, "ip", ip)
+}
+
+func (g *LoginGuard) clear(ctx context.Context, kind, name string) error {
+	return g.rdb.Del(ctx, g.key(kind, name, "fail"), g.key(kind, name, "lock"), g.key(kind, name, "level")).Err()
+}
+
+// key 同一账号/IP的几个key使用{}包住，保证集群模式下落在同一个slot
+func (g *LoginGuard) key(kind, name, suffix string) string {
+	return g.rdb.WrapKey(fmt.Sprintf("%s:%s:{%s}:%s", g.conf.KeyPrefix, kind, name, suffix))
+}
+
+// Guard 在登录处理函数开始时调用，账号或IP被锁定时中断请求并返回false
+func (g *LoginGuard) Guard(c *gin.Context, account string) bool {
+	err := g.Check(c.Request.Context(), account, GetRequestIP(c))
+	if err == nil {
+		return true
+	}
+	AbortLocked(c, err)
+	return false
+}
+
+// Failed 登录失败时调用，触发锁定时返回*LockedError，可交给AbortLocked响应
+func (g *LoginGuard) Failed(c *gin.Context, account string) error {
+	return g.Fail(c.Request.Context(), account, GetRequestIP(c))
+}
+
+// AbortLocked 响应锁定错误：设置Retry-After头，返回的data中包含retryAfter(秒)
+func AbortLocked(c *gin.Context, err error) {
+	locked, ok := err.(*LockedError)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, service.ErrInternalServerError)
+		return
+	}
+	seconds := ceilSeconds(locked.RetryAfter)
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, service.ErrLoginLocked.WithData(gin.H{"retryAfter": seconds}))
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/18689221165/lynn-toolkit/gojwt"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuth2错误响应，格式遵循RFC 6749，便于第三方使用标准的OAuth2客户端对接
+func oauthError(c *gin.Context, status int, code string) {
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(status, gin.H{"error": code})
+}
+
+// clientCredentials 从Basic认证头或表单中读取客户端ID和密钥
+func clientCredentials(c *gin.Context) (string, string) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		return id, secret
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret")
+}
+
+// OAuthToken OAuth2 client_credentials授权模式的token端点，需使用POST表单请求
+func OAuthToken(conf gojwt.Conf, store gojwt.ClientStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.PostForm("grant_type") != "client_credentials" {
+			oauthError(c, http.StatusBadRequest, "unsupported_grant_type")
+			return
+		}
+
+		clientID, secret := clientCredentials(c)
+		token, scopes, err := gojwt.IssueClientToken(c.Request.Context(), store, clientID, secret, c.PostForm("scope"), conf)
+		if errors.Is(err, gojwt.ErrInvalidClient) {
+			oauthError(c, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+		if errors.Is(err, gojwt.ErrInvalidScope) {
+			oauthError(c, http.StatusBadRequest, "invalid_scope")
+			return
+		}
+		if err != nil {
+			oauthError(c, http.StatusInternalServerError, "server_error")
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": token.Token,
+			"token_type":   "Bearer",
+			"expires_in":   token.EffectiveTime,
+			"scope":        strings.Join(scopes, " "),
+		})
+	}
+}
+
+// OAuthIntrospect token自省端点(RFC 7662)，调用方需使用已注册的客户端认证
+func OAuthIntrospect(conf gojwt.Conf, store gojwt.ClientStore) gin.HandlerFunc {
+	policy := conf.Policy()
+	return func(c *gin.Context) {
+		clientID, secret := clientCredentials(c)
+		if _, err := gojwt.AuthenticateClient(c.Request.Context(), store, clientID, secret); err != nil {
+			oauthError(c, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+
+		claims, err := gojwt.ParseTokenWithPolicy(trimBearer(c.PostForm("token")), conf.Secret, policy)
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{"active": false})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"active":     true,
+			"token_type": "Bearer",
+			"scope":      claims.Scope,
+			"sub":        claims.Subject,
+			"role":       claims.Role,
+			"iss":        claims.Issuer,
+			"aud":        claims.Audience,
+			"exp":        claims.ExpiresAt,
+			"iat":        claims.IssuedAt,
+		})
+	}
+}
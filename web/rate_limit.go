@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/18689221165/lynn-toolkit/redis"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RateKeyFunc 从请求中提取限流的key，返回空字符串时不限流
+type RateKeyFunc func(c *gin.Context) string
+
+// RateKeyByIP 按客户端IP限流
+func RateKeyByIP(c *gin.Context) string {
+	return "ip:" + GetRequestIP(c)
+}
+
+// RateKeyByUser 按登录用户限流，需放在JWTAuth之后使用，未登录时不限流
+func RateKeyByUser(c *gin.Context) string {
+	if uid := CurrentUID(c); uid != "" {
+		return "user:" + uid
+	}
+	return ""
+}
+
+// RateKeyByAPI 按接口限流，所有调用方共享额度
+func RateKeyByAPI(c *gin.Context) string {
+	return "api:" + c.Request.Method + ":" + c.FullPath()
+}
+
+// RateLimit 分布式限流中间件，响应头中返回X-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset(秒)，
+// 超过限制时返回429及Retry-After头；redis不可用时放行，不影响业务
+func RateLimit(limiter *redis.RateLimiter, keyFunc RateKeyFunc, rule redis.RateRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := limiter.Allow(c.Request.Context(), key, rule)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+		if !res.Allowed {
+			seconds := ceilSeconds(res.RetryAfter)
+			c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, service.ErrRateLimited.WithData(gin.H{"retryAfter": seconds}))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ceilSeconds 向上取整到秒
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/18689221165/lynn-toolkit/rbac"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission 权限校验中间件，需放在JWTAuth之后使用
+// 从jwt claims中读取角色，角色需同时拥有permissions中的所有权限才能访问
+func RequirePermission(enforcer *rbac.Enforcer, permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := CurrentClaims(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, service.ErrTokenMissing)
+			return
+		}
+		for _, perm := range permissions {
+			if !enforcer.Allow(c.Request.Context(), claims.Role, perm) {
+				c.AbortWithStatusJSON(http.StatusForbidden, service.ErrForbidden)
+				return
+			}
+		}
+		c.Next()
+	}
+}
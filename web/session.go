@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/18689221165/lynn-toolkit/gojwt"
+	"github.com/18689221165/lynn-toolkit/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ListSessions 列出当前登录用户的所有在线会话，需放在JWTAuth之后使用
+func ListSessions(sessions *gojwt.SessionRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := sessions.List(c.Request.Context(), CurrentUID(c))
+		if err != nil {
+			c.JSON(http.StatusOK, service.ErrInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, service.Succ(list))
+	}
+}
+
+// RevokeSession 注销当前登录用户的指定会话(踢下线)，会话ID从路由参数paramName中读取
+func RevokeSession(sessions *gojwt.SessionRegistry, paramName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sid := c.Param(paramName)
+		if sid == "" {
+			c.JSON(http.StatusOK, service.ErrBadParamInput)
+			return
+		}
+		if err := sessions.Revoke(c.Request.Context(), CurrentUID(c), sid); err != nil {
+			c.JSON(http.StatusOK, service.ErrInternalServerError)
+			return
+		}
+		c.JSON(http.StatusOK, service.Succ(nil))
+	}
+}
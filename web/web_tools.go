@@ -1,6 +1,7 @@
 package web
 
 import (
+	"github.com/18689221165/lynn-toolkit/idworker"
 	"github.com/gin-gonic/gin"
 	"strconv"
 	"strings"
@@ -31,6 +32,18 @@ func UIntParam(c *gin.Context, paramName string) (uint64, error) {
 	return strconv.ParseUint(oidstr, 10, 64)
 }
 
+// PublicIdParam 读取对外公开的编码ID参数(如base62短ID、混淆ID)，解码为Uint64
+func PublicIdParam(c *gin.Context, paramName string, codec idworker.IdCodec) (uint64, error) {
+	code := strings.TrimPrefix(c.Param(paramName), "/")
+	if code == "" {
+		code = c.Query(paramName)
+	}
+	if code == "" {
+		code = c.PostForm(paramName)
+	}
+	return codec.Decode(code)
+}
+
 func GetJwtToken(c *gin.Context, authKey string) string {
 	if r := c.GetHeader(authKey); r != "" {
 		return r